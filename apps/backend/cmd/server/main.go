@@ -2,12 +2,29 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 
 	"hookinator/internal/database"
+	"hookinator/internal/forwarder"
+	"hookinator/internal/handlers"
+	"hookinator/internal/ingest"
+	"hookinator/internal/janitor"
+	"hookinator/internal/ratelimit"
+	"hookinator/internal/rejections"
 	"hookinator/internal/router"
+	"hookinator/internal/secrets"
+	"hookinator/internal/stream"
+	"hookinator/internal/utils"
 
 	"github.com/joho/godotenv"
 )
@@ -41,15 +58,234 @@ func main() {
 	if jwtSecret == "" {
 		log.Fatal("FATAL: JWT_SECRET environment variable is not set")
 	}
+	trustedProxies, err := utils.ParseCIDRs(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("FATAL: invalid TRUSTED_PROXIES: %v", err)
+	}
+	// Webhook secrets are encrypted with the master key; without one the
+	// secret endpoints, stored-secret verification and outbound signing are
+	// unavailable.
+	var secretBox *secrets.Box
+	if masterKey := os.Getenv("SECRETS_MASTER_KEY"); masterKey != "" {
+		key, err := secrets.ParseKey(masterKey)
+		if err != nil {
+			log.Fatalf("FATAL: invalid SECRETS_MASTER_KEY: %v", err)
+		}
+		if secretBox, err = secrets.New(key); err != nil {
+			log.Fatalf("FATAL: invalid SECRETS_MASTER_KEY: %v", err)
+		}
+	} else {
+		log.Println("SECRETS_MASTER_KEY is not set, webhook secrets are disabled")
+	}
+	// Deliveries never reach loopback, private or link-local addresses,
+	// since users read back what their targets respond. FORWARD_ALLOWED_CIDRS
+	// opts ranges back in, e.g. 127.0.0.1 for local development.
+	allowedNetworks, err := utils.ParseCIDRs(os.Getenv("FORWARD_ALLOWED_CIDRS"))
+	if err != nil {
+		log.Fatalf("FATAL: invalid FORWARD_ALLOWED_CIDRS: %v", err)
+	}
+	forwarderConfig := forwarder.Config{
+		Workers:      getEnvInt("FORWARD_WORKERS", 4),
+		MaxAttempts:  getEnvInt("FORWARD_MAX_ATTEMPTS", 8),
+		BaseBackoff:  getEnvDuration("FORWARD_BACKOFF_BASE", 5*time.Second),
+		MaxBackoff:   getEnvDuration("FORWARD_BACKOFF_MAX", time.Hour),
+		PollInterval: getEnvDuration("FORWARD_POLL_INTERVAL", time.Second),
+
+		AllowedNetworks: allowedNetworks,
+	}
+	// Ingestion limits. Buckets live in process unless RATE_LIMIT_BACKEND is
+	// postgres, which shares them between instances; a rate of 0 turns a
+	// limit off. The per-IP limit is off by default because providers send
+	// from shared addresses; behind a proxy it also needs TRUSTED_PROXIES,
+	// or every sender shares the proxy's bucket. The concurrency cap
+	// defaults to three quarters of the connection pool, leaving the rest
+	// for the API and the forwarder.
+	ingestLimits := handlers.IngestLimits{
+		PerWebhook: ratelimit.Limit{
+			Rate:  float64(getEnvLimit("RATE_LIMIT_WEBHOOK_RPS", 50)),
+			Burst: getEnvInt("RATE_LIMIT_WEBHOOK_BURST", 100),
+		},
+		PerIP: ratelimit.Limit{
+			Rate:  float64(getEnvLimit("RATE_LIMIT_IP_RPS", 0)),
+			Burst: getEnvInt("RATE_LIMIT_IP_BURST", 40),
+		},
+		MaxConcurrent: getEnvInt("INGEST_MAX_CONCURRENCY", max(1, db.Stats().MaxOpenConnections*3/4)),
+		MaxBodyBytes:  int64(getEnvLimit("INGEST_MAX_BODY_BYTES", 10<<20)),
+	}
+	switch backend := os.Getenv("RATE_LIMIT_BACKEND"); backend {
+	case "", "memory":
+		ingestLimits.Limiter = ratelimit.NewMemory()
+	case "postgres":
+		ingestLimits.Limiter = ratelimit.NewPostgres(db)
+	case "off":
+	default:
+		log.Fatalf("FATAL: RATE_LIMIT_BACKEND must be memory, postgres or off, got %q", backend)
+	}
+	// With INGEST_BUFFER_SIZE set, captured requests are acknowledged once
+	// buffered and saved in batches. Requests still in the buffer are lost if
+	// the process crashes, so by default each one is saved before replying.
+	var ingestConfig *ingest.Config
+	if os.Getenv("INGEST_BUFFER_SIZE") != "" {
+		ingestConfig = &ingest.Config{
+			Size:          getEnvInt("INGEST_BUFFER_SIZE", 0),
+			MaxBytes:      int64(getEnvInt("INGEST_BUFFER_MAX_BYTES", 64<<20)),
+			BatchSize:     getEnvInt("INGEST_BATCH_SIZE", 200),
+			FlushInterval: getEnvDuration("INGEST_FLUSH_INTERVAL", 50*time.Millisecond),
+		}
+	}
+	// Serving TLS directly lets webhooks authenticate senders by client
+	// certificate. Certificates are requested but not required, since most
+	// webhooks do not use them; with a client CA they must also chain to it.
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if (certFile == "") != (keyFile == "") {
+		log.Fatal("FATAL: TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	var tlsConfig *tls.Config
+	if certFile != "" {
+		tlsConfig = &tls.Config{ClientAuth: tls.RequestClientCert}
+		if caFile := os.Getenv("TLS_CLIENT_CA_FILE"); caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				log.Fatalf("FATAL: failed to read TLS_CLIENT_CA_FILE: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				log.Fatal("FATAL: TLS_CLIENT_CA_FILE contains no certificates")
+			}
+			tlsConfig.ClientCAs = pool
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	// The janitor deletes expired webhooks and prunes requests beyond the
+	// retention policy. These limits apply to every webhook without its own;
+	// by default requests are kept forever.
+	janitorInterval := getEnvDuration("JANITOR_INTERVAL", time.Minute)
+	retention := database.RetentionPolicy{
+		MaxAgeSeconds: int64(getEnvDuration("RETENTION_MAX_AGE", 0).Seconds()),
+		MaxCount:      int64(getEnvInt("RETENTION_MAX_COUNT", 0)),
+		MaxBytes:      int64(getEnvInt("RETENTION_MAX_BYTES", 0)),
+	}
 	// --- End of configuration loading ---
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fwd := forwarder.New(db, secretBox, forwarderConfig)
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		fwd.Run(ctx)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		janitor.New(db, janitorInterval, retention).Run(ctx)
+	}()
+
+	hub := stream.New(db)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		hub.Run(ctx)
+	}()
+
+	// Rejections are counted in memory and written in batches.
+	rejectionCounter := rejections.New(db, getEnvDuration("REJECTION_FLUSH_INTERVAL", 10*time.Second))
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		rejectionCounter.Run(ctx)
+	}()
+
+	var buffer *ingest.Buffer
+	if ingestConfig != nil {
+		buffer = ingest.New(db, *ingestConfig, fwd.Notify)
+		go buffer.Run()
+	}
+
 	// Pass the configuration to the router
-	r := router.New(db, baseURL, jwtSecret)
+	r := router.New(db, fwd, buffer, hub, rejectionCounter, secretBox, baseURL, jwtSecret, trustedProxies, ingestLimits, retention)
+	// Slow senders would otherwise hold connections and ingestion slots
+	// open indefinitely. Streams clear the read deadline themselves.
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           r,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", 10*time.Second),
+		ReadTimeout:       getEnvDuration("HTTP_READ_TIMEOUT", time.Minute),
+	}
+	// Open streams would otherwise keep Shutdown waiting until it times out.
+	srv.RegisterOnShutdown(hub.Close)
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		log.Println("shutting down server...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("server shutdown failed: %v", err)
+		}
+	}()
 
 	log.Printf("starting server on port: %s", port)
-	err = http.ListenAndServe(":"+port, r)
+	if tlsConfig != nil {
+		err = srv.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
 
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("server failed to start: %v", err)
 	}
-}
\ No newline at end of file
+
+	// Shutdown returns once in-flight requests have finished, so nothing is
+	// submitted to the buffer after it is drained.
+	<-shutdownDone
+	if buffer != nil {
+		buffer.Close()
+	}
+	rejectionCounter.Flush()
+	stop()
+	workers.Wait()
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Fatalf("FATAL: %s must be a positive integer, got %q", key, value)
+	}
+	return n
+}
+
+// getEnvLimit is getEnvInt for limits, which 0 turns off.
+func getEnvLimit(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Fatalf("FATAL: %s must be a non-negative integer, got %q", key, value)
+	}
+	return n
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Fatalf("FATAL: %s must be a positive duration such as 30s, got %q", key, value)
+	}
+	return d
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// maxRowsPerInsert keeps a multi-row insert well under Postgres' limit of
+// 65535 bind parameters.
+const maxRowsPerInsert = 1000
+
+// PendingRequest is a captured request waiting to be saved by SaveRequests.
+type PendingRequest struct {
+	WebhookID string
+	Request   WebhookRequest
+	Options   SaveOptions
+}
+
+type dedupOriginal struct {
+	id         int64
+	receivedAt time.Time
+}
+
+// SaveRequests saves a batch of requests in one transaction with multi-row
+// inserts, deduplicating and queueing deliveries as SaveRequest does for
+// each. It sets Request.ID and Request.DuplicateOf of every entry. If a
+// webhook of the batch was deleted, nothing is saved and ErrWebhookGone is
+// returned.
+func (db *DB) SaveRequests(ctx context.Context, batch []PendingRequest) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// IDs are allocated up front so that duplicates within the batch can
+	// point at their original and rows never have to be matched back to
+	// requests by RETURNING order.
+	rows, err := tx.QueryContext(ctx, `SELECT nextval(pg_get_serial_sequence('requests', 'request_id')) FROM generate_series(1, $1)`, len(batch))
+	if err != nil {
+		return fmt.Errorf("failed to allocate request IDs: %w", err)
+	}
+	for i := 0; rows.Next(); i++ {
+		if err := rows.Scan(&batch[i].Request.ID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan request ID: %w", err)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	var lockKeys []string
+	for _, p := range batch {
+		if p.Request.DedupKey != "" {
+			lockKeys = append(lockKeys, dedupLockKey(p.WebhookID, p.Request.DedupKey))
+		}
+	}
+	if len(lockKeys) > 0 {
+		if err := lockDedupKeys(ctx, tx, lockKeys); err != nil {
+			return fmt.Errorf("failed to lock dedup keys: %w", err)
+		}
+	}
+	originals := make(map[string]dedupOriginal)
+	for i := range batch {
+		p := &batch[i]
+		if p.Request.DedupKey == "" {
+			continue
+		}
+		key := dedupLockKey(p.WebhookID, p.Request.DedupKey)
+		since := p.Request.Timestamp.Add(-p.Options.DedupWindow)
+		original, seen := originals[key]
+		if !seen || !original.receivedAt.After(since) {
+			id, receivedAt, found, err := findOriginal(ctx, tx, p.WebhookID, p.Request.DedupKey, since)
+			if err != nil {
+				return fmt.Errorf("failed to look up dedup key for webhook %s: %w", p.WebhookID, err)
+			}
+			original, seen = dedupOriginal{id: id, receivedAt: receivedAt}, found
+		}
+		if seen {
+			p.Request.DuplicateOf = &original.id
+		} else {
+			original = dedupOriginal{id: p.Request.ID, receivedAt: p.Request.Timestamp}
+		}
+		originals[key] = original
+	}
+
+	for start := 0; start < len(batch); start += maxRowsPerInsert {
+		chunk := batch[start:min(start+maxRowsPerInsert, len(batch))]
+		values := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*(requestInsertColumnCount+1))
+		for i := range chunk {
+			p := &chunk[i]
+			rowArgs, err := requestInsertArgs(p.WebhookID, &p.Request)
+			if err != nil {
+				return err
+			}
+			values = append(values, placeholders(len(args), requestInsertColumnCount+1))
+			args = append(args, p.Request.ID)
+			args = append(args, rowArgs...)
+		}
+		query := `INSERT INTO requests (request_id, ` + requestInsertColumns + `) VALUES ` + strings.Join(values, ", ")
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+				return ErrWebhookGone
+			}
+			return fmt.Errorf("failed to save batch of %d requests: %w", len(chunk), err)
+		}
+	}
+
+	var requestIDs, savedIDs []int64
+	var webhookIDs, savedWebhookIDs []string
+	for _, p := range batch {
+		savedIDs = append(savedIDs, p.Request.ID)
+		savedWebhookIDs = append(savedWebhookIDs, p.WebhookID)
+		if p.Request.DuplicateOf == nil && !p.Options.SkipDelivery {
+			requestIDs = append(requestIDs, p.Request.ID)
+			webhookIDs = append(webhookIDs, p.WebhookID)
+		}
+	}
+	if len(requestIDs) > 0 {
+		if err := enqueueDeliveries(ctx, tx, requestIDs, webhookIDs); err != nil {
+			return fmt.Errorf("failed to queue deliveries for batch: %w", err)
+		}
+	}
+	if err := notifyRequests(ctx, tx, savedIDs, savedWebhookIDs); err != nil {
+		return fmt.Errorf("failed to announce batch of %d requests: %w", len(batch), err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch of %d requests: %w", len(batch), err)
+	}
+	return nil
+}
@@ -4,13 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"hookinator/internal/utils"
+
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib" // Postgres driver
 )
 
@@ -21,10 +27,56 @@ type DB struct {
 
 // WebhookRequest represents a single webhook request captured.
 type WebhookRequest struct {
+	ID        int64       `json:"id"`
+	// PublicID is the stable, time-sortable ULID that addresses the request
+	// in the API.
+	PublicID  string      `json:"public_id"`
+	WebhookID string      `json:"webhook_id"`
 	Timestamp time.Time   `json:"timestamp"`
 	Method    string      `json:"method"`
+	Path      string      `json:"path"`
+	Query     string      `json:"query"`
 	Headers   http.Header `json:"headers"`
 	Body      string      `json:"body"`
+
+	// Connection metadata. RemoteIP is the client address after resolving
+	// trusted proxies; RemoteAddr is the direct peer. ContentLength is what
+	// the sender declared (-1 if unknown), BodySize what was actually read.
+	RemoteIP      string `json:"remote_ip"`
+	RemoteAddr    string `json:"remote_addr"`
+	Host          string `json:"host"`
+	Proto         string `json:"proto"`
+	ContentLength int64  `json:"content_length"`
+	BodySize      int64  `json:"body_size"`
+	TLSVersion    string `json:"tls_version,omitempty"`
+	TLSServerName string `json:"tls_server_name,omitempty"`
+
+	// StubID is the stub that answered the request, if any.
+	StubID *int64 `json:"stub_id,omitempty"`
+
+	// SignatureStatus is "valid" or "invalid" when the webhook verifies
+	// signatures, with SignatureError explaining a failure.
+	SignatureStatus string `json:"signature_status,omitempty"`
+	SignatureError  string `json:"signature_error,omitempty"`
+
+	// DedupKey is the provider delivery ID of the request. DuplicateOf is the
+	// earlier request with the same key, if this one is a retry; duplicates
+	// are not forwarded.
+	DedupKey    string `json:"dedup_key,omitempty"`
+	DuplicateOf *int64 `json:"duplicate_of,omitempty"`
+
+	// EventType is the provider event type, such as "push" or
+	// "invoice.paid", if one was found.
+	EventType string `json:"event_type,omitempty"`
+
+	// ValidationErrors lists how the body fails the webhook's JSON Schema;
+	// it is empty if the body conforms or no schema is set.
+	ValidationErrors []ValidationError `json:"validation_errors,omitempty"`
+
+	// Pinned requests are kept regardless of the retention policy.
+	Pinned bool `json:"pinned"`
+
+	LastDelivery *DeliveryStatus `json:"last_delivery,omitempty"`
 }
 
 // New connects to the database and returns a DB instance.
@@ -60,6 +112,19 @@ func New() (*DB, error) {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
 
+	// Bound the pool so a traffic spike queues in the server, where
+	// ingestion can shed it, instead of exhausting Postgres connections.
+	maxOpenConns := 25
+	if value := os.Getenv("DB_MAX_OPEN_CONNS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("DB_MAX_OPEN_CONNS must be a positive integer, got %q", value)
+		}
+		maxOpenConns = n
+	}
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxOpenConns)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -112,6 +177,168 @@ func (db *DB) Migrate(ctx context.Context) error {
 		received_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 	);`
 
+	deletedWebhooksTable := `
+	CREATE TABLE IF NOT EXISTS deleted_webhooks (
+		id VARCHAR(255) PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL,
+		deleted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	rejectionsTable := `
+	CREATE TABLE IF NOT EXISTS rejections (
+		webhook_id VARCHAR(255) NOT NULL,
+		reason VARCHAR(50) NOT NULL,
+		count BIGINT NOT NULL DEFAULT 0,
+		last_rejected_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (webhook_id, reason)
+	);`
+
+	// Rejections of IDs that never existed are kept apart, and only for the
+	// most recently seen IDs, since senders choose them.
+	unknownRejectionsTable := `
+	CREATE TABLE IF NOT EXISTS unknown_rejections (
+		webhook_id VARCHAR(255) NOT NULL,
+		reason VARCHAR(50) NOT NULL,
+		count BIGINT NOT NULL DEFAULT 0,
+		last_rejected_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (webhook_id, reason)
+	);
+	CREATE INDEX IF NOT EXISTS unknown_rejections_last_idx ON unknown_rejections (last_rejected_at);`
+
+	blockedRequestsTable := `
+	CREATE TABLE IF NOT EXISTS blocked_requests (
+		id BIGSERIAL PRIMARY KEY,
+		webhook_id VARCHAR(255) NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		reason VARCHAR(50) NOT NULL,
+		remote_ip TEXT NOT NULL DEFAULT '',
+		method TEXT NOT NULL DEFAULT '',
+		path TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS blocked_requests_webhook_idx ON blocked_requests (webhook_id, id);`
+
+	// Rate limit buckets are cheap to lose, so the table skips the WAL.
+	rateLimitsTable := `
+	CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+		key TEXT PRIMARY KEY,
+		tokens DOUBLE PRECISION NOT NULL,
+		allowed BOOLEAN NOT NULL,
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);`
+
+	// Stream tickets live for seconds, so they skip the WAL as well.
+	streamTicketsTable := `
+	CREATE UNLOGGED TABLE IF NOT EXISTS stream_tickets (
+		ticket_hash TEXT PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL,
+		webhook_id VARCHAR(255) NOT NULL,
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);`
+
+	destinationsTable := `
+	CREATE TABLE IF NOT EXISTS destinations (
+		id BIGSERIAL PRIMARY KEY,
+		webhook_id VARCHAR(255) NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		url TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		timeout_ms INTEGER NOT NULL DEFAULT 10000,
+		headers JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS destinations_webhook_idx ON destinations (webhook_id);`
+
+	stubsTable := `
+	CREATE TABLE IF NOT EXISTS stubs (
+		id BIGSERIAL PRIMARY KEY,
+		webhook_id VARCHAR(255) NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL DEFAULT '',
+		priority INTEGER NOT NULL DEFAULT 0,
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		match JSONB NOT NULL DEFAULT '{}',
+		response JSONB NOT NULL DEFAULT '{}',
+		fault JSONB,
+		scenario VARCHAR(255) NOT NULL DEFAULT '',
+		required_state VARCHAR(255) NOT NULL DEFAULT '',
+		new_state VARCHAR(255) NOT NULL DEFAULT '',
+		transition_after INTEGER NOT NULL DEFAULT 0,
+		hits BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS stubs_webhook_idx ON stubs (webhook_id, priority, id);`
+
+	scenariosTable := `
+	CREATE TABLE IF NOT EXISTS scenarios (
+		webhook_id VARCHAR(255) NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		state VARCHAR(255) NOT NULL,
+		state_hits INTEGER NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (webhook_id, name)
+	);`
+
+	webhookSecretsTable := `
+	CREATE TABLE IF NOT EXISTS webhook_secrets (
+		id BIGSERIAL PRIMARY KEY,
+		webhook_id VARCHAR(255) NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		purpose VARCHAR(20) NOT NULL,
+		ciphertext BYTEA NOT NULL,
+		hint VARCHAR(8) NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP WITH TIME ZONE,
+		revealed_at TIMESTAMP WITH TIME ZONE,
+		revoked_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE INDEX IF NOT EXISTS webhook_secrets_webhook_idx ON webhook_secrets (webhook_id, purpose);`
+
+	deliveryQueueTable := `
+	CREATE TABLE IF NOT EXISTS delivery_queue (
+		id BIGSERIAL PRIMARY KEY,
+		request_id INTEGER NOT NULL REFERENCES requests(request_id) ON DELETE CASCADE,
+		webhook_id VARCHAR(255) NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		target_url TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		locked_until TIMESTAMP WITH TIME ZONE,
+		last_error TEXT,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS delivery_queue_due_idx
+		ON delivery_queue (next_attempt_at) WHERE status IN ('pending', 'in_flight');`
+
+	deliveriesTable := `
+	CREATE TABLE IF NOT EXISTS deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		request_id INTEGER NOT NULL REFERENCES requests(request_id) ON DELETE CASCADE,
+		webhook_id VARCHAR(255) NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		job_id BIGINT REFERENCES delivery_queue(id) ON DELETE SET NULL,
+		target_url TEXT NOT NULL,
+		attempt INTEGER NOT NULL,
+		status_code INTEGER,
+		duration_ms BIGINT NOT NULL,
+		response_headers JSONB,
+		response_body TEXT,
+		error TEXT,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS deliveries_request_idx ON deliveries (request_id, id);`
+
+	bulkReplaysTable := `
+	CREATE TABLE IF NOT EXISTS bulk_replays (
+		id TEXT PRIMARY KEY,
+		webhook_id VARCHAR(255) NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		total INTEGER NOT NULL,
+		sent INTEGER NOT NULL DEFAULT 0,
+		status VARCHAR(20) NOT NULL DEFAULT 'running',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS bulk_replays_webhook_idx ON bulk_replays (webhook_id);`
+
 	if _, err := db.ExecContext(ctx, userTable); err != nil {
 		return fmt.Errorf("failed to create users table: %w", err)
 	}
@@ -121,12 +348,102 @@ func (db *DB) Migrate(ctx context.Context) error {
 	if _, err := db.ExecContext(ctx, requestsTable); err != nil {
 		return fmt.Errorf("failed to create requests table: %w", err)
 	}
+	if _, err := db.ExecContext(ctx, deletedWebhooksTable); err != nil {
+		return fmt.Errorf("failed to create deleted_webhooks table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, rejectionsTable); err != nil {
+		return fmt.Errorf("failed to create rejections table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, unknownRejectionsTable); err != nil {
+		return fmt.Errorf("failed to create unknown_rejections table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, blockedRequestsTable); err != nil {
+		return fmt.Errorf("failed to create blocked_requests table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, rateLimitsTable); err != nil {
+		return fmt.Errorf("failed to create rate_limits table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, streamTicketsTable); err != nil {
+		return fmt.Errorf("failed to create stream_tickets table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, destinationsTable); err != nil {
+		return fmt.Errorf("failed to create destinations table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, stubsTable); err != nil {
+		return fmt.Errorf("failed to create stubs table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, scenariosTable); err != nil {
+		return fmt.Errorf("failed to create scenarios table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, webhookSecretsTable); err != nil {
+		return fmt.Errorf("failed to create webhook_secrets table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, deliveryQueueTable); err != nil {
+		return fmt.Errorf("failed to create delivery_queue table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, deliveriesTable); err != nil {
+		return fmt.Errorf("failed to create deliveries table: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, bulkReplaysTable); err != nil {
+		return fmt.Errorf("failed to create bulk_replays table: %w", err)
+	}
 
 	// Add missing columns to existing tables if they don't exist
 	addMissingColumns := []string{
 		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS user_id VARCHAR(255) NOT NULL DEFAULT 'default_user'`,
 		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS name VARCHAR(255)`,
 		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS source_type VARCHAR(50)`,
+		`ALTER TABLE delivery_queue ADD COLUMN IF NOT EXISTS destination_id BIGINT REFERENCES destinations(id) ON DELETE CASCADE`,
+		`ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS destination_id BIGINT REFERENCES destinations(id) ON DELETE SET NULL`,
+		`ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS replay BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS response_config JSONB`,
+		`ALTER TABLE requests ALTER COLUMN method TYPE TEXT`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS path TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS query TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS remote_ip TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS remote_addr TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS host TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS proto TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS content_length BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS body_size BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS tls_version TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS tls_server_name TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS stub_id BIGINT REFERENCES stubs(id) ON DELETE SET NULL`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS verification_config JSONB`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS signature_status TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS signature_error TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS dedup_config JSONB`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS ip_filter JSONB`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS rate_limit JSONB`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS ingest_auth JSONB`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS dedup_key TEXT`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS duplicate_of INTEGER REFERENCES requests(request_id) ON DELETE SET NULL`,
+		`CREATE INDEX IF NOT EXISTS requests_dedup_idx ON requests (webhook_id, dedup_key, received_at) WHERE dedup_key IS NOT NULL`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS schema_config JSONB`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS validation_errors JSONB`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS event_type_config JSONB`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS event_type TEXT NOT NULL DEFAULT ''`,
+		`CREATE INDEX IF NOT EXISTS requests_event_type_idx ON requests (webhook_id, event_type, received_at)`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS disabled_status_code INTEGER NOT NULL DEFAULT 503`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS retry_after_seconds INTEGER NOT NULL DEFAULT 300`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE`,
+		`ALTER TABLE deleted_webhooks ADD COLUMN IF NOT EXISTS expired BOOLEAN NOT NULL DEFAULT FALSE`,
+		`CREATE INDEX IF NOT EXISTS webhooks_expires_at_idx ON webhooks (expires_at) WHERE expires_at IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS requests_webhook_received_idx ON requests (webhook_id, received_at DESC, request_id DESC)`,
+		// body_json is only filled for requests captured after it was added.
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS body_json JSONB`,
+		`CREATE INDEX IF NOT EXISTS requests_body_json_idx ON requests USING GIN (body_json jsonb_path_ops)`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS public_id TEXT`,
+		`ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS retention JSONB`,
+		`ALTER TABLE requests ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE`,
+		`CREATE INDEX IF NOT EXISTS delivery_queue_request_idx ON delivery_queue (request_id)`,
+		`ALTER TABLE blocked_requests ADD COLUMN IF NOT EXISTS hits BIGINT NOT NULL DEFAULT 1`,
+		`ALTER TABLE blocked_requests ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS blocked_requests_source_idx ON blocked_requests (webhook_id, remote_ip, reason, id)`,
+		// Unknown IDs used to share one counter under the empty ID.
+		`DELETE FROM rejections WHERE webhook_id = ''`,
+		`CREATE INDEX IF NOT EXISTS requests_search_idx ON requests USING GIN ((` + strings.ReplaceAll(requestSearchVector, "r.", "") + `))`,
 	}
 
 	for _, query := range addMissingColumns {
@@ -135,29 +452,269 @@ func (db *DB) Migrate(ctx context.Context) error {
 		}
 	}
 
+	// Public IDs are how clients address requests, so unlike the columns
+	// above they must not be left out.
+	if err := db.backfillPublicIDs(ctx); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `ALTER TABLE requests ALTER COLUMN public_id SET NOT NULL`); err != nil {
+		return fmt.Errorf("failed to make public_id required: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS requests_public_id_idx ON requests (public_id)`); err != nil {
+		return fmt.Errorf("failed to create requests_public_id_idx: %w", err)
+	}
+
 	log.Println("Database migration completed successfully.")
 	return nil
 }
 
-// SaveRequest saves a webhook request to the database.
-func (db *DB) SaveRequest(ctx context.Context, webhookID string, req WebhookRequest) error {
-	// FIX: Marshal headers into a JSON string for the JSONB column.
-	headersJSON, err := json.Marshal(req.Headers)
+// SaveOptions controls how SaveRequest treats a request.
+type SaveOptions struct {
+	// DedupWindow is how far back a request with the same DedupKey makes
+	// this one a duplicate.
+	DedupWindow time.Duration
+	// SkipDelivery captures the request without queueing any delivery.
+	SkipDelivery bool
+}
+
+// SaveRequest saves a webhook request to the database and, in the same
+// transaction, queues one delivery for the webhook's forward URL and one for
+// each of its enabled destinations, unless opts.SkipDelivery is set. It sets
+// req.ID and, for duplicates, req.DuplicateOf.
+func (db *DB) SaveRequest(ctx context.Context, webhookID string, req *WebhookRequest, opts SaveOptions) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to marshal headers to JSON: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	query := `
-	INSERT INTO requests (webhook_id, method, headers, body, received_at)
-	VALUES ($1, $2, $3, $4, $5)`
+	if req.DedupKey != "" {
+		// Concurrent retries with the same key queue up here, so exactly one
+		// of them is the original.
+		if err := lockDedupKeys(ctx, tx, []string{dedupLockKey(webhookID, req.DedupKey)}); err != nil {
+			return fmt.Errorf("failed to lock dedup key for webhook %s: %w", webhookID, err)
+		}
+		original, _, found, err := findOriginal(ctx, tx, webhookID, req.DedupKey, req.Timestamp.Add(-opts.DedupWindow))
+		if err != nil {
+			return fmt.Errorf("failed to look up dedup key for webhook %s: %w", webhookID, err)
+		}
+		if found {
+			req.DuplicateOf = &original
+		}
+	}
 
-	_, err = db.ExecContext(ctx, query, webhookID, req.Method, headersJSON, req.Body, req.Timestamp)
+	args, err := requestInsertArgs(webhookID, req)
 	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO requests (` + requestInsertColumns + `)
+	VALUES ` + placeholders(0, requestInsertColumnCount) + `
+	RETURNING request_id`
+
+	var requestID int64
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&requestID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+			// The webhook was deleted after it was looked up.
+			return ErrWebhookGone
+		}
 		return fmt.Errorf("failed to save request for webhook %s: %w", webhookID, err)
 	}
+	req.ID = requestID
+
+	if req.DuplicateOf == nil && !opts.SkipDelivery {
+		if err := enqueueDeliveries(ctx, tx, []int64{requestID}, []string{webhookID}); err != nil {
+			return fmt.Errorf("failed to queue delivery for webhook %s: %w", webhookID, err)
+		}
+	}
+	if err := notifyRequests(ctx, tx, []int64{requestID}, []string{webhookID}); err != nil {
+		return fmt.Errorf("failed to announce request for webhook %s: %w", webhookID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit request for webhook %s: %w", webhookID, err)
+	}
+	return nil
+}
+
+// backfillPublicIDs gives a public ID to requests captured before they had
+// one, in batches.
+func (db *DB) backfillPublicIDs(ctx context.Context) error {
+	const batchSize = 1000
+	total := 0
+	for {
+		rows, err := db.QueryContext(ctx, `
+		SELECT request_id, COALESCE(received_at, CURRENT_TIMESTAMP)
+		FROM requests
+		WHERE public_id IS NULL
+		LIMIT $1`, batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to query requests without public IDs: %w", err)
+		}
+		var ids []int64
+		var publicIDs []string
+		for rows.Next() {
+			var id int64
+			var receivedAt time.Time
+			if err := rows.Scan(&id, &receivedAt); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan request row: %w", err)
+			}
+			publicID, err := utils.NewULID(receivedAt)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to generate public ID: %w", err)
+			}
+			ids = append(ids, id)
+			publicIDs = append(publicIDs, publicID)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error during rows iteration: %w", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		_, err = db.ExecContext(ctx, `
+		UPDATE requests SET public_id = v.public_id
+		FROM unnest($1::bigint[], $2::text[]) AS v(request_id, public_id)
+		WHERE requests.request_id = v.request_id`, ids, publicIDs)
+		if err != nil {
+			return fmt.Errorf("failed to backfill public IDs: %w", err)
+		}
+		total += len(ids)
+	}
+	if total > 0 {
+		log.Printf("assigned public IDs to %d requests", total)
+	}
 	return nil
 }
 
+// requestInsertColumns are the columns SaveRequest and SaveRequests fill,
+// in the order of requestInsertArgs.
+const requestInsertColumns = `webhook_id, method, path, query, headers, body, received_at,
+		remote_ip, remote_addr, host, proto, content_length, body_size, tls_version, tls_server_name, stub_id,
+		signature_status, signature_error, dedup_key, duplicate_of, validation_errors, event_type, body_json, public_id`
+
+const requestInsertColumnCount = 24
+
+// requestInsertArgs returns the values of requestInsertColumns for a request,
+// assigning it a public ID if it has none.
+func requestInsertArgs(webhookID string, req *WebhookRequest) ([]interface{}, error) {
+	if req.PublicID == "" {
+		publicID, err := utils.NewULID(req.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate public ID: %w", err)
+		}
+		req.PublicID = publicID
+	}
+	// FIX: Marshal headers into a JSON string for the JSONB column.
+	headersJSON, err := json.Marshal(req.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal headers to JSON: %w", err)
+	}
+	var validationJSON []byte
+	if len(req.ValidationErrors) > 0 {
+		if validationJSON, err = json.Marshal(req.ValidationErrors); err != nil {
+			return nil, fmt.Errorf("failed to marshal validation errors: %w", err)
+		}
+	}
+	var dedupKey sql.NullString
+	if req.DedupKey != "" {
+		dedupKey = sql.NullString{String: req.DedupKey, Valid: true}
+	}
+	return []interface{}{webhookID, req.Method, req.Path, req.Query, headersJSON, req.Body, req.Timestamp,
+		req.RemoteIP, req.RemoteAddr, req.Host, req.Proto, req.ContentLength, req.BodySize, req.TLSVersion, req.TLSServerName, req.StubID,
+		req.SignatureStatus, req.SignatureError, dedupKey, req.DuplicateOf, validationJSON, req.EventType, bodyJSON(req.Body), req.PublicID}, nil
+}
+
+// bodyJSON returns the body for the body_json column, or nil if it is not
+// JSON that Postgres accepts: jsonb cannot hold the NUL character.
+func bodyJSON(body string) []byte {
+	if body == "" || !json.Valid([]byte(body)) || strings.Contains(body, `\u0000`) {
+		return nil
+	}
+	return []byte(body)
+}
+
+// placeholders returns "($n+1, ..., $n+count)".
+func placeholders(n, count int) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i := 1; i <= count; i++ {
+		if i > 1 {
+			b.WriteString(", ")
+		}
+		b.WriteString("$" + strconv.Itoa(n+i))
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func dedupLockKey(webhookID, dedupKey string) string {
+	return webhookID + ":" + dedupKey
+}
+
+// lockDedupKeys takes transaction-scoped advisory locks on dedup keys. Keys
+// are locked in sorted order so that transactions locking several cannot
+// deadlock.
+func lockDedupKeys(ctx context.Context, tx *sql.Tx, keys []string) error {
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i > 0 && key == keys[i-1] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findOriginal looks up the most recent request of a webhook with a dedup
+// key that is not itself a duplicate and was received after since.
+func findOriginal(ctx context.Context, tx *sql.Tx, webhookID, dedupKey string, since time.Time) (int64, time.Time, bool, error) {
+	var original int64
+	var receivedAt time.Time
+	lookup := `
+	SELECT request_id, received_at FROM requests
+	WHERE webhook_id = $1 AND dedup_key = $2 AND duplicate_of IS NULL AND received_at > $3
+	ORDER BY request_id DESC
+	LIMIT 1`
+	err := tx.QueryRowContext(ctx, lookup, webhookID, dedupKey, since).Scan(&original, &receivedAt)
+	switch {
+	case err == nil:
+		return original, receivedAt, true, nil
+	case err == sql.ErrNoRows:
+		return 0, time.Time{}, false, nil
+	default:
+		return 0, time.Time{}, false, err
+	}
+}
+
+// enqueueDeliveries queues, for each request, one delivery for its webhook's
+// forward URL and one for each of the webhook's enabled destinations.
+// requestIDs and webhookIDs are parallel.
+func enqueueDeliveries(ctx context.Context, tx *sql.Tx, requestIDs []int64, webhookIDs []string) error {
+	enqueue := `
+	WITH saved AS (
+		SELECT * FROM unnest($1::bigint[], $2::text[]) AS s(request_id, webhook_id)
+	)
+	INSERT INTO delivery_queue (request_id, webhook_id, destination_id, target_url)
+	SELECT s.request_id, w.id, NULL, w.forward_url
+	FROM saved s JOIN webhooks w ON w.id = s.webhook_id
+	WHERE w.forward_url IS NOT NULL AND w.forward_url <> ''
+	UNION ALL
+	SELECT s.request_id, d.webhook_id, d.id, d.url
+	FROM saved s JOIN destinations d ON d.webhook_id = s.webhook_id
+	WHERE d.enabled`
+	_, err := tx.ExecContext(ctx, enqueue, requestIDs, webhookIDs)
+	return err
+}
+
 // UpsertUser creates a new user or updates their email if they already exist.
 func (db *DB) UpsertUser(ctx context.Context, id, email string) error {
 	// First, try to insert with the new ID
@@ -184,16 +741,17 @@ func (db *DB) UpsertUser(ctx context.Context, id, email string) error {
 }
 
 // CreateWebhook creates or updates a webhook entry for a specific user.
-func (db *DB) CreateWebhook(ctx context.Context, id, userID, forwardURL, name, sourceType string) error {
+func (db *DB) CreateWebhook(ctx context.Context, id, userID, forwardURL, name, sourceType string, expiresAt *time.Time) error {
 	query := `
-	INSERT INTO webhooks (id, user_id, forward_url, name, source_type) 
-	VALUES ($1, $2, $3, $4, $5) 
+	INSERT INTO webhooks (id, user_id, forward_url, name, source_type, expires_at) 
+	VALUES ($1, $2, $3, $4, $5, $6) 
 	ON CONFLICT (id) DO UPDATE SET 
 		forward_url = EXCLUDED.forward_url,
 		name = EXCLUDED.name,
-		source_type = EXCLUDED.source_type;
+		source_type = EXCLUDED.source_type,
+		expires_at = EXCLUDED.expires_at;
 	`
-	_, err := db.ExecContext(ctx, query, id, userID, forwardURL, name, sourceType)
+	_, err := db.ExecContext(ctx, query, id, userID, forwardURL, name, sourceType, expiresAt)
 	return err
 }
 
@@ -214,7 +772,7 @@ func (db *DB) GetForwardURL(ctx context.Context, webhookID string) (string, erro
 // GetWebhooksForUser retrieves all webhooks for a given user.
 func (db *DB) GetWebhooksForUser(ctx context.Context, userID string) ([]map[string]interface{}, error) {
 	query := `
-	SELECT id, user_id, forward_url, name, source_type, created_at
+	SELECT id, user_id, forward_url, name, source_type, created_at, status, expires_at
 	FROM webhooks
 	WHERE user_id = $1
 	ORDER BY created_at DESC;
@@ -227,9 +785,10 @@ func (db *DB) GetWebhooksForUser(ctx context.Context, userID string) ([]map[stri
 
 	var webhooks []map[string]interface{}
 	for rows.Next() {
-		var id, dbUserID, forwardURL, name, sourceType string
+		var id, dbUserID, forwardURL, name, sourceType, status string
 		var createdAt time.Time
-		if err := rows.Scan(&id, &dbUserID, &forwardURL, &name, &sourceType, &createdAt); err != nil {
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&id, &dbUserID, &forwardURL, &name, &sourceType, &createdAt, &status, &expiresAt); err != nil {
 			return nil, fmt.Errorf("failed to scan webhook row: %w", err)
 		}
 		webhooks = append(webhooks, map[string]interface{}{
@@ -239,6 +798,8 @@ func (db *DB) GetWebhooksForUser(ctx context.Context, userID string) ([]map[stri
 			"name":         name,
 			"source_type":  sourceType,
 			"created_at":   createdAt.Format(time.RFC3339),
+			"status":       status,
+			"expires_at":   formatNullTime(expiresAt),
 		})
 	}
 	
@@ -249,38 +810,179 @@ func (db *DB) GetWebhooksForUser(ctx context.Context, userID string) ([]map[stri
 	return webhooks, nil
 }
 
-// GetRequests retrieves webhook requests from the database for a given webhook ID.
-func (db *DB) GetRequests(ctx context.Context, webhookID string, limit int) ([]WebhookRequest, error) {
-	query := `
-	SELECT r.method, r.headers, r.body, r.received_at
+// requestSelect selects captured requests (aliased r) together with their
+// latest delivery attempt; scanRequest reads its rows.
+const requestSelect = `
+	SELECT r.request_id, r.public_id, r.webhook_id, r.method, r.path, r.query, r.headers, r.body, r.received_at,
+		r.remote_ip, r.remote_addr, r.host, r.proto, r.content_length, r.body_size, r.tls_version, r.tls_server_name,
+		r.stub_id, r.signature_status, r.signature_error, COALESCE(r.dedup_key, ''), r.duplicate_of,
+		r.validation_errors, r.event_type, r.pinned,
+		d.target_url, d.attempt, d.status_code, d.error, d.created_at
 	FROM requests r
-	JOIN webhooks w ON r.webhook_id = w.id
-	WHERE r.webhook_id = $1
-	ORDER BY r.received_at DESC
-	LIMIT $2`
+	LEFT JOIN LATERAL (
+		SELECT target_url, attempt, status_code, error, created_at
+		FROM deliveries
+		WHERE request_id = r.request_id
+		ORDER BY id DESC
+		LIMIT 1
+	) d ON true`
+
+func scanRequest(row rowScanner) (WebhookRequest, error) {
+	var req WebhookRequest
+	var headersJSON []byte // Scan the JSONB data into a byte slice
+	var validationJSON []byte
+	var last nullDeliveryStatus
+	var stubID, duplicateOf sql.NullInt64
 
-	rows, err := db.QueryContext(ctx, query, webhookID, limit)
+	err := row.Scan(&req.ID, &req.PublicID, &req.WebhookID, &req.Method, &req.Path, &req.Query, &headersJSON, &req.Body, &req.Timestamp,
+		&req.RemoteIP, &req.RemoteAddr, &req.Host, &req.Proto, &req.ContentLength, &req.BodySize, &req.TLSVersion, &req.TLSServerName,
+		&stubID, &req.SignatureStatus, &req.SignatureError, &req.DedupKey, &duplicateOf,
+		&validationJSON, &req.EventType, &req.Pinned,
+		&last.TargetURL, &last.Attempt, &last.StatusCode, &last.Error, &last.CreatedAt)
 	if err != nil {
+		return WebhookRequest{}, err
+	}
+	req.LastDelivery = last.status()
+	if stubID.Valid {
+		req.StubID = &stubID.Int64
+	}
+	if duplicateOf.Valid {
+		req.DuplicateOf = &duplicateOf.Int64
+	}
+	if len(validationJSON) > 0 {
+		if err := json.Unmarshal(validationJSON, &req.ValidationErrors); err != nil {
+			log.Printf("Warning: failed to unmarshal validation errors for request %d: %v", req.ID, err)
+		}
+	}
+
+	// FIX: Unmarshal the JSON byte slice into the headers map.
+	if err := json.Unmarshal(headersJSON, &req.Headers); err != nil {
+		log.Printf("Warning: failed to unmarshal headers for a request: %v", err)
+		req.Headers = nil
+	}
+	return req, nil
+}
+
+// RequestFilter narrows the requests GetRequests returns. Zero fields match
+// every request.
+type RequestFilter struct {
+	Method    string
+	EventType string
+	// Header is a header the request must carry, in canonical form; with
+	// HeaderValue it must have that value.
+	Header      string
+	HeaderValue string
+	Since       time.Time
+	Until       time.Time
+	// BodyContains is a case-sensitive substring of the body.
+	BodyContains string
+	// Text is a free-text query over bodies and headers, in web search
+	// syntax: words, "quoted phrases", OR and -excluded words.
+	Text string
+	// JSONPath is a Postgres jsonpath predicate the JSON body must satisfy,
+	// such as $.data.object.id == "ch_123".
+	JSONPath string
+	// After continues a listing after the last request of a previous page.
+	After *RequestCursor
+}
+
+// RequestCursor is the position of a request in the newest-first order of
+// GetRequests.
+type RequestCursor struct {
+	ReceivedAt time.Time
+	ID         int64
+}
+
+// ErrInvalidJSONPath is returned when a filter's JSONPath is not a valid
+// jsonpath expression.
+var ErrInvalidJSONPath = errors.New("invalid jsonpath")
+
+// requestSearchVector is the text-search document of a request. Its input
+// is truncated because a tsvector cannot exceed 1MB, and must match the
+// expression of requests_search_idx for the index to be used.
+const requestSearchVector = `to_tsvector('simple', left(COALESCE(r.body, ''), 100000) || ' ' || left(COALESCE(r.headers::text, ''), 10000))`
+
+// requestConditions returns the SQL conditions on requests (aliased r) that
+// apply filter, appending their arguments to args. Only the criteria in use
+// are included so that the planner can pick the matching index.
+func requestConditions(filter RequestFilter, args []interface{}) ([]string, []interface{}) {
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	var conds []string
+	if filter.Method != "" {
+		conds = append(conds, "r.method = "+arg(filter.Method))
+	}
+	if filter.EventType != "" {
+		conds = append(conds, "r.event_type = "+arg(filter.EventType))
+	}
+	if filter.Header != "" {
+		header := arg(filter.Header)
+		if filter.HeaderValue != "" {
+			conds = append(conds, "r.headers -> "+header+" @> jsonb_build_array("+arg(filter.HeaderValue)+"::text)")
+		} else {
+			conds = append(conds, "r.headers -> "+header+" IS NOT NULL")
+		}
+	}
+	if !filter.Since.IsZero() {
+		conds = append(conds, "r.received_at >= "+arg(filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		conds = append(conds, "r.received_at < "+arg(filter.Until))
+	}
+	if filter.BodyContains != "" {
+		conds = append(conds, "strpos(r.body, "+arg(filter.BodyContains)+") > 0")
+	}
+	if filter.Text != "" {
+		conds = append(conds, requestSearchVector+" @@ websearch_to_tsquery('simple', "+arg(filter.Text)+")")
+	}
+	if filter.JSONPath != "" {
+		conds = append(conds, "r.body_json @@ "+arg(filter.JSONPath)+"::jsonpath")
+	}
+	if filter.After != nil {
+		conds = append(conds, "(r.received_at, r.request_id) < ("+arg(filter.After.ReceivedAt)+", "+arg(filter.After.ID)+"::bigint)")
+	}
+	return conds, args
+}
+
+// GetRequests retrieves webhook requests from the database for a given webhook ID.
+// Requests are returned newest first, ordered by (received_at, request_id) so
+// that cursors are stable.
+func (db *DB) GetRequests(ctx context.Context, webhookID string, filter RequestFilter, limit int) ([]WebhookRequest, error) {
+	return db.queryRequests(ctx, "r.webhook_id = $1", webhookID, filter, limit)
+}
+
+// SearchRequests is GetRequests across all of a user's webhooks.
+func (db *DB) SearchRequests(ctx context.Context, userID string, filter RequestFilter, limit int) ([]WebhookRequest, error) {
+	return db.queryRequests(ctx, "r.webhook_id IN (SELECT id FROM webhooks WHERE user_id = $1)", userID, filter, limit)
+}
+
+// queryRequests lists the requests in scope, whose condition takes
+// scopeArg as $1, that match filter.
+func (db *DB) queryRequests(ctx context.Context, scope string, scopeArg string, filter RequestFilter, limit int) ([]WebhookRequest, error) {
+	conds, args := requestConditions(filter, []interface{}{scopeArg})
+	args = append(args, limit)
+	query := requestSelect + `
+	WHERE ` + strings.Join(append([]string{scope}, conds...), " AND ") + `
+	ORDER BY r.received_at DESC, r.request_id DESC
+	LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		if jsonPathErr := invalidJSONPath(filter, err); jsonPathErr != nil {
+			return nil, jsonPathErr
+		}
 		return nil, fmt.Errorf("failed to query requests: %w", err)
 	}
 	defer rows.Close()
 
 	var requests []WebhookRequest
 	for rows.Next() {
-		var req WebhookRequest
-		var headersJSON []byte // Scan the JSONB data into a byte slice
-
-		err := rows.Scan(&req.Method, &headersJSON, &req.Body, &req.Timestamp)
+		req, err := scanRequest(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan request row: %w", err)
 		}
-
-		// FIX: Unmarshal the JSON byte slice into the headers map.
-		if err := json.Unmarshal(headersJSON, &req.Headers); err != nil {
-			log.Printf("Warning: failed to unmarshal headers for a request: %v", err)
-			req.Headers = nil
-		}
-
 		requests = append(requests, req)
 	}
 
@@ -291,16 +993,43 @@ func (db *DB) GetRequests(ctx context.Context, webhookID string, limit int) ([]W
 	return requests, nil
 }
 
+// invalidJSONPath returns ErrInvalidJSONPath, with Postgres' explanation,
+// if err is Postgres rejecting filter's JSONPath, and nil otherwise.
+func invalidJSONPath(filter RequestFilter, err error) error {
+	var pgErr *pgconn.PgError
+	if filter.JSONPath != "" && errors.As(err, &pgErr) && (pgErr.Code == "42601" || strings.HasPrefix(pgErr.Code, "22")) {
+		return fmt.Errorf("%w: %s", ErrInvalidJSONPath, pgErr.Message)
+	}
+	return nil
+}
+
+// GetRequest retrieves a single captured request of a webhook. It returns
+// sql.ErrNoRows if the request does not belong to the webhook.
+func (db *DB) GetRequest(ctx context.Context, webhookID string, requestID int64) (WebhookRequest, error) {
+	query := requestSelect + `
+	WHERE r.webhook_id = $1 AND r.request_id = $2`
+
+	req, err := scanRequest(db.QueryRowContext(ctx, query, webhookID, requestID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return WebhookRequest{}, err
+		}
+		return WebhookRequest{}, fmt.Errorf("failed to query request: %w", err)
+	}
+	return req, nil
+}
+
 // GetWebhookByID retrieves a single webhook by ID for a specific user.
 func (db *DB) GetWebhookByID(ctx context.Context, webhookID, userID string) (map[string]interface{}, error) {
 	query := `
-	SELECT id, user_id, forward_url, name, source_type, created_at
+	SELECT id, user_id, forward_url, name, source_type, created_at, status, expires_at
 	FROM webhooks
 	WHERE id = $1 AND user_id = $2;
 	`
-	var id, dbUserID, forwardURL, name, sourceType string
+	var id, dbUserID, forwardURL, name, sourceType, status string
 	var createdAt time.Time
-	err := db.QueryRowContext(ctx, query, webhookID, userID).Scan(&id, &dbUserID, &forwardURL, &name, &sourceType, &createdAt)
+	var expiresAt sql.NullTime
+	err := db.QueryRowContext(ctx, query, webhookID, userID).Scan(&id, &dbUserID, &forwardURL, &name, &sourceType, &createdAt, &status, &expiresAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("webhook not found")
@@ -315,9 +1044,20 @@ func (db *DB) GetWebhookByID(ctx context.Context, webhookID, userID string) (map
 		"name":         name,
 		"source_type":  sourceType,
 		"created_at":   createdAt.Format(time.RFC3339),
+		"status":       status,
+		"expires_at":   formatNullTime(expiresAt),
 	}, nil
 }
 
+// formatNullTime formats a nullable timestamp for the webhook maps, as nil
+// when it is unset.
+func formatNullTime(t sql.NullTime) interface{} {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time.Format(time.RFC3339)
+}
+
 // CheckWebhookOwnership verifies that a webhook belongs to a specific user.
 func (db *DB) CheckWebhookOwnership(ctx context.Context, webhookID, userID string) (bool, error) {
 	var exists bool
@@ -329,10 +1069,17 @@ func (db *DB) CheckWebhookOwnership(ctx context.Context, webhookID, userID strin
 	return exists, nil
 }
 
-// DeleteWebhook deletes a webhook by ID for a specific user.
+// DeleteWebhook deletes a webhook by ID for a specific user and leaves a
+// tombstone so senders still using its URL are told it is gone.
 func (db *DB) DeleteWebhook(ctx context.Context, webhookID, userID string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`
-	result, err := db.ExecContext(ctx, query, webhookID, userID)
+	result, err := tx.ExecContext(ctx, query, webhookID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete webhook: %w", err)
 	}
@@ -345,14 +1092,25 @@ func (db *DB) DeleteWebhook(ctx context.Context, webhookID, userID string) error
 	if rowsAffected == 0 {
 		return fmt.Errorf("webhook not found or not owned by user")
 	}
-	
+
+	tombstone := `
+	INSERT INTO deleted_webhooks (id, user_id) VALUES ($1, $2)
+	ON CONFLICT (id) DO UPDATE SET user_id = EXCLUDED.user_id, deleted_at = NOW(), expired = FALSE`
+	if _, err := tx.ExecContext(ctx, tombstone, webhookID, userID); err != nil {
+		return fmt.Errorf("failed to record deleted webhook: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit webhook deletion: %w", err)
+	}
 	return nil
 }
 
-// UpdateWebhook updates a webhook's forward URL and name for a specific user.
-func (db *DB) UpdateWebhook(ctx context.Context, webhookID, userID, forwardURL, name string) error {
-	query := `UPDATE webhooks SET forward_url = $1, name = $2 WHERE id = $3 AND user_id = $4`
-	result, err := db.ExecContext(ctx, query, forwardURL, name, webhookID, userID)
+// UpdateWebhook updates a webhook's forward URL and name for a specific user,
+// and its source type unless sourceType is empty.
+func (db *DB) UpdateWebhook(ctx context.Context, webhookID, userID, forwardURL, name, sourceType string) error {
+	query := `UPDATE webhooks SET forward_url = $1, name = $2, source_type = COALESCE(NULLIF($5, ''), source_type) WHERE id = $3 AND user_id = $4`
+	result, err := db.ExecContext(ctx, query, forwardURL, name, webhookID, userID, sourceType)
 	if err != nil {
 		return fmt.Errorf("failed to update webhook: %w", err)
 	}
@@ -369,6 +1127,37 @@ func (db *DB) UpdateWebhook(ctx context.Context, webhookID, userID, forwardURL,
 	return nil
 }
 
+// LookupRequestID returns the internal ID of a webhook's request from its
+// public ID, or sql.ErrNoRows if the webhook has no such request.
+func (db *DB) LookupRequestID(ctx context.Context, webhookID, publicID string) (int64, error) {
+	var id int64
+	err := db.QueryRowContext(ctx, `SELECT request_id FROM requests WHERE webhook_id = $1 AND public_id = $2`, webhookID, publicID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("failed to look up request: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteRequest deletes one request of a webhook together with its
+// deliveries. It returns sql.ErrNoRows if the webhook has no such request.
+func (db *DB) DeleteRequest(ctx context.Context, webhookID string, requestID int64) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM requests WHERE request_id = $1 AND webhook_id = $2`, requestID, webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to delete request: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // ClearWebhookRequests deletes all requests for a specific webhook
 func (db *DB) ClearWebhookRequests(ctx context.Context, webhookID, userID string) error {
 	// First verify ownership
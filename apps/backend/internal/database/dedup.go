@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// DedupConfig turns on deduplication of provider retries. Requests carrying
+// a key already seen within the window are captured but flagged as
+// duplicates and not forwarded. Without a header or JSON path the built-in
+// provider delivery IDs are used.
+type DedupConfig struct {
+	Header        string `json:"header,omitempty"`
+	JSONPath      string `json:"json_path,omitempty"`
+	WindowSeconds int    `json:"window_seconds,omitempty"`
+}
+
+// GetDedupConfig retrieves the dedup config of a user's webhook, or nil if
+// duplicates are not detected.
+func (db *DB) GetDedupConfig(ctx context.Context, webhookID, userID string) (*DedupConfig, error) {
+	var configJSON []byte
+	query := `SELECT dedup_config FROM webhooks WHERE id = $1 AND user_id = $2`
+	if err := db.QueryRowContext(ctx, query, webhookID, userID).Scan(&configJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to query dedup config: %w", err)
+	}
+	if len(configJSON) == 0 {
+		return nil, nil
+	}
+	var cfg DedupConfig
+	if err := json.Unmarshal(configJSON, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dedup config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// UpdateDedupConfig sets the dedup config of a user's webhook; nil turns
+// deduplication off.
+func (db *DB) UpdateDedupConfig(ctx context.Context, webhookID, userID string, cfg *DedupConfig) error {
+	var configJSON []byte
+	if cfg != nil {
+		var err error
+		if configJSON, err = json.Marshal(cfg); err != nil {
+			return fmt.Errorf("failed to marshal dedup config: %w", err)
+		}
+	}
+
+	query := `UPDATE webhooks SET dedup_config = $1 WHERE id = $2 AND user_id = $3`
+	result, err := db.ExecContext(ctx, query, configJSON, webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update dedup config: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Delivery is a single attempt to forward a captured request.
+type Delivery struct {
+	ID              int64       `json:"id"`
+	RequestID       int64       `json:"request_id"`
+	JobID           *int64      `json:"job_id,omitempty"`
+	DestinationID   *int64      `json:"destination_id,omitempty"`
+	TargetURL       string      `json:"target_url"`
+	Attempt         int         `json:"attempt"`
+	Replay          bool        `json:"replay"`
+	StatusCode      int         `json:"status_code,omitempty"`
+	DurationMS      int64       `json:"duration_ms"`
+	ResponseHeaders http.Header `json:"response_headers,omitempty"`
+	ResponseBody    string      `json:"response_body,omitempty"`
+	Error           string      `json:"error,omitempty"`
+	CreatedAt       time.Time   `json:"created_at"`
+}
+
+// DeliveryStatus summarises the most recent delivery attempt of a request.
+type DeliveryStatus struct {
+	TargetURL  string    `json:"target_url"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Succeeded  bool      `json:"succeeded"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// nullDeliveryStatus scans a DeliveryStatus from a LEFT JOIN that may not
+// have matched any delivery.
+type nullDeliveryStatus struct {
+	TargetURL  sql.NullString
+	Attempt    sql.NullInt64
+	StatusCode sql.NullInt64
+	Error      sql.NullString
+	CreatedAt  sql.NullTime
+}
+
+func (n nullDeliveryStatus) status() *DeliveryStatus {
+	if !n.TargetURL.Valid {
+		return nil
+	}
+	return &DeliveryStatus{
+		TargetURL:  n.TargetURL.String,
+		Attempt:    int(n.Attempt.Int64),
+		StatusCode: int(n.StatusCode.Int64),
+		Error:      n.Error.String,
+		Succeeded:  n.Error.String == "" && n.StatusCode.Int64 >= 200 && n.StatusCode.Int64 <= 299,
+		CreatedAt:  n.CreatedAt.Time,
+	}
+}
+
+// SaveDelivery records a delivery attempt.
+func (db *DB) SaveDelivery(ctx context.Context, webhookID string, d Delivery) error {
+	headersJSON, err := json.Marshal(d.ResponseHeaders)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response headers to JSON: %w", err)
+	}
+
+	var statusCode sql.NullInt64
+	if d.StatusCode != 0 {
+		statusCode = sql.NullInt64{Int64: int64(d.StatusCode), Valid: true}
+	}
+	var errText sql.NullString
+	if d.Error != "" {
+		errText = sql.NullString{String: d.Error, Valid: true}
+	}
+
+	query := `
+	INSERT INTO deliveries (request_id, webhook_id, job_id, destination_id, target_url, attempt, replay, status_code, duration_ms, response_headers, response_body, error)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err = db.ExecContext(ctx, query, d.RequestID, webhookID, d.JobID, d.DestinationID, d.TargetURL, d.Attempt, d.Replay,
+		statusCode, d.DurationMS, headersJSON, d.ResponseBody, errText)
+	if err != nil {
+		return fmt.Errorf("failed to save delivery for request %d: %w", d.RequestID, err)
+	}
+	return nil
+}
+
+// GetDeliveries retrieves every delivery attempt of a request, oldest first.
+// It returns sql.ErrNoRows if the request does not belong to the webhook.
+func (db *DB) GetDeliveries(ctx context.Context, webhookID string, requestID int64) ([]Delivery, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM requests WHERE request_id = $1 AND webhook_id = $2)`, requestID, webhookID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check request: %w", err)
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	query := `
+	SELECT id, request_id, job_id, destination_id, target_url, attempt, replay, status_code, duration_ms, response_headers, response_body, error, created_at
+	FROM deliveries
+	WHERE request_id = $1
+	ORDER BY id`
+
+	rows, err := db.QueryContext(ctx, query, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		var jobID, destinationID, statusCode sql.NullInt64
+		var body, errText sql.NullString
+		var headersJSON []byte
+		if err := rows.Scan(&d.ID, &d.RequestID, &jobID, &destinationID, &d.TargetURL, &d.Attempt, &d.Replay, &statusCode, &d.DurationMS, &headersJSON, &body, &errText, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery row: %w", err)
+		}
+		if jobID.Valid {
+			d.JobID = &jobID.Int64
+		}
+		if destinationID.Valid {
+			d.DestinationID = &destinationID.Int64
+		}
+		d.StatusCode = int(statusCode.Int64)
+		d.ResponseBody = body.String
+		d.Error = errText.String
+		if len(headersJSON) > 0 {
+			if err := json.Unmarshal(headersJSON, &d.ResponseHeaders); err != nil {
+				log.Printf("Warning: failed to unmarshal response headers for delivery %d: %v", d.ID, err)
+			}
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return deliveries, nil
+}
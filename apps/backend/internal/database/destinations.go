@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Destination is an additional URL that captured requests are fanned out to.
+type Destination struct {
+	ID        int64             `json:"id"`
+	WebhookID string            `json:"webhook_id"`
+	URL       string            `json:"url"`
+	Enabled   bool              `json:"enabled"`
+	TimeoutMS int               `json:"timeout_ms"`
+	Headers   map[string]string `json:"headers"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// GetDestinations retrieves all destinations of a webhook.
+func (db *DB) GetDestinations(ctx context.Context, webhookID string) ([]Destination, error) {
+	query := `
+	SELECT id, webhook_id, url, enabled, timeout_ms, headers, created_at, updated_at
+	FROM destinations
+	WHERE webhook_id = $1
+	ORDER BY id`
+
+	rows, err := db.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query destinations: %w", err)
+	}
+	defer rows.Close()
+
+	destinations := []Destination{}
+	for rows.Next() {
+		d, err := scanDestination(rows)
+		if err != nil {
+			return nil, err
+		}
+		destinations = append(destinations, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return destinations, nil
+}
+
+// CreateDestination adds a destination to a webhook and returns it.
+func (db *DB) CreateDestination(ctx context.Context, d Destination) (Destination, error) {
+	headersJSON, err := json.Marshal(d.Headers)
+	if err != nil {
+		return Destination{}, fmt.Errorf("failed to marshal destination headers: %w", err)
+	}
+
+	query := `
+	INSERT INTO destinations (webhook_id, url, enabled, timeout_ms, headers)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, webhook_id, url, enabled, timeout_ms, headers, created_at, updated_at`
+
+	created, err := scanDestination(db.QueryRowContext(ctx, query, d.WebhookID, d.URL, d.Enabled, d.TimeoutMS, headersJSON))
+	if err != nil {
+		return Destination{}, fmt.Errorf("failed to create destination: %w", err)
+	}
+	return created, nil
+}
+
+// UpdateDestination replaces a destination's settings. It returns
+// sql.ErrNoRows if the destination does not belong to the webhook.
+func (db *DB) UpdateDestination(ctx context.Context, d Destination) (Destination, error) {
+	headersJSON, err := json.Marshal(d.Headers)
+	if err != nil {
+		return Destination{}, fmt.Errorf("failed to marshal destination headers: %w", err)
+	}
+
+	query := `
+	UPDATE destinations
+	SET url = $3, enabled = $4, timeout_ms = $5, headers = $6, updated_at = NOW()
+	WHERE id = $1 AND webhook_id = $2
+	RETURNING id, webhook_id, url, enabled, timeout_ms, headers, created_at, updated_at`
+
+	updated, err := scanDestination(db.QueryRowContext(ctx, query, d.ID, d.WebhookID, d.URL, d.Enabled, d.TimeoutMS, headersJSON))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Destination{}, err
+		}
+		return Destination{}, fmt.Errorf("failed to update destination: %w", err)
+	}
+	return updated, nil
+}
+
+// DeleteDestination removes a destination along with its pending deliveries.
+// It returns sql.ErrNoRows if the destination does not belong to the webhook.
+func (db *DB) DeleteDestination(ctx context.Context, webhookID string, destinationID int64) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM destinations WHERE id = $1 AND webhook_id = $2`, destinationID, webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to delete destination: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDestination(row rowScanner) (Destination, error) {
+	var d Destination
+	var headersJSON []byte
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Enabled, &d.TimeoutMS, &headersJSON, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return Destination{}, err
+		}
+		return Destination{}, fmt.Errorf("failed to scan destination row: %w", err)
+	}
+	if err := json.Unmarshal(headersJSON, &d.Headers); err != nil {
+		return Destination{}, fmt.Errorf("failed to unmarshal destination headers: %w", err)
+	}
+	if d.Headers == nil {
+		d.Headers = map[string]string{}
+	}
+	return d, nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// EventTypeConfig locates the event type of incoming requests, in a header
+// or at a JSON path of the body, for providers the built-in rules do not
+// cover.
+type EventTypeConfig struct {
+	Header   string `json:"header,omitempty"`
+	JSONPath string `json:"json_path,omitempty"`
+}
+
+// EventTypeCount is how many captured requests of a webhook have an event
+// type; requests without one are counted under "".
+type EventTypeCount struct {
+	EventType string `json:"event_type"`
+	Count     int64  `json:"count"`
+}
+
+// GetEventTypeConfig retrieves the event type rule of a user's webhook, or
+// nil if it uses the built-in rule of its source type.
+func (db *DB) GetEventTypeConfig(ctx context.Context, webhookID, userID string) (*EventTypeConfig, error) {
+	var configJSON []byte
+	query := `SELECT event_type_config FROM webhooks WHERE id = $1 AND user_id = $2`
+	if err := db.QueryRowContext(ctx, query, webhookID, userID).Scan(&configJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to query event type config: %w", err)
+	}
+	if len(configJSON) == 0 {
+		return nil, nil
+	}
+	var cfg EventTypeConfig
+	if err := json.Unmarshal(configJSON, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event type config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// UpdateEventTypeConfig sets the event type rule of a user's webhook; nil
+// goes back to the built-in rule.
+func (db *DB) UpdateEventTypeConfig(ctx context.Context, webhookID, userID string, cfg *EventTypeConfig) error {
+	var configJSON []byte
+	if cfg != nil {
+		var err error
+		if configJSON, err = json.Marshal(cfg); err != nil {
+			return fmt.Errorf("failed to marshal event type config: %w", err)
+		}
+	}
+
+	query := `UPDATE webhooks SET event_type_config = $1 WHERE id = $2 AND user_id = $3`
+	result, err := db.ExecContext(ctx, query, configJSON, webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update event type config: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CountEventTypes counts the captured requests of a webhook by event type,
+// most frequent first.
+func (db *DB) CountEventTypes(ctx context.Context, webhookID string) ([]EventTypeCount, error) {
+	query := `
+	SELECT event_type, COUNT(*) FROM requests
+	WHERE webhook_id = $1
+	GROUP BY event_type
+	ORDER BY COUNT(*) DESC, event_type`
+
+	rows, err := db.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count event types: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []EventTypeCount{}
+	for rows.Next() {
+		var c EventTypeCount
+		if err := rows.Scan(&c.EventType, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan event type count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return counts, nil
+}
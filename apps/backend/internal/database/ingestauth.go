@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// IngestAuthConfig requires senders to authenticate. Passwords and tokens
+// are stored as SHA-256 digests; mTLS pins client certificates by their
+// SHA-256 fingerprint.
+type IngestAuthConfig struct {
+	Mode         string   `json:"mode"`
+	Username     string   `json:"username,omitempty"`
+	SecretHash   string   `json:"secret_hash,omitempty"`
+	QueryParam   string   `json:"query_param,omitempty"`
+	Fingerprints []string `json:"fingerprints,omitempty"`
+}
+
+// GetIngestAuth retrieves the ingestion auth of a user's webhook, or nil if
+// it is public.
+func (db *DB) GetIngestAuth(ctx context.Context, webhookID, userID string) (*IngestAuthConfig, error) {
+	var configJSON []byte
+	query := `SELECT ingest_auth FROM webhooks WHERE id = $1 AND user_id = $2`
+	if err := db.QueryRowContext(ctx, query, webhookID, userID).Scan(&configJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to query ingest auth: %w", err)
+	}
+	if len(configJSON) == 0 {
+		return nil, nil
+	}
+	var cfg IngestAuthConfig
+	if err := json.Unmarshal(configJSON, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ingest auth: %w", err)
+	}
+	return &cfg, nil
+}
+
+// UpdateIngestAuth sets the ingestion auth of a user's webhook; nil makes it
+// public again.
+func (db *DB) UpdateIngestAuth(ctx context.Context, webhookID, userID string, cfg *IngestAuthConfig) error {
+	var configJSON []byte
+	if cfg != nil {
+		var err error
+		if configJSON, err = json.Marshal(cfg); err != nil {
+			return fmt.Errorf("failed to marshal ingest auth: %w", err)
+		}
+	}
+
+	query := `UPDATE webhooks SET ingest_auth = $1 WHERE id = $2 AND user_id = $3`
+	result, err := db.ExecContext(ctx, query, configJSON, webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update ingest auth: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// IPFilterConfig restricts which client addresses may send requests to a
+// webhook. Entries are CIDRs or single addresses; presets add a provider's
+// published ranges to the allowlist.
+type IPFilterConfig struct {
+	Allow   []string `json:"allow"`
+	Deny    []string `json:"deny"`
+	Presets []string `json:"presets"`
+}
+
+// BlockedRequest is an incoming request that was refused before being
+// captured.
+type BlockedRequest struct {
+	ID        int64     `json:"id"`
+	WebhookID string    `json:"webhook_id"`
+	Reason    string    `json:"reason"`
+	RemoteIP  string    `json:"remote_ip"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	UserAgent string    `json:"user_agent"`
+	// Hits counts the requests from the same address refused for the same
+	// reason within BlockedAggregateWindow of each other; the other fields
+	// describe the latest.
+	Hits       int64     `json:"hits"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// BlockedAggregateWindow is how long repeated refusals of one address are
+// counted on the same entry.
+const BlockedAggregateWindow = time.Hour
+
+// GetIPFilter retrieves the IP filter of a user's webhook, or nil if every
+// address is allowed.
+func (db *DB) GetIPFilter(ctx context.Context, webhookID, userID string) (*IPFilterConfig, error) {
+	var configJSON []byte
+	query := `SELECT ip_filter FROM webhooks WHERE id = $1 AND user_id = $2`
+	if err := db.QueryRowContext(ctx, query, webhookID, userID).Scan(&configJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to query IP filter: %w", err)
+	}
+	if len(configJSON) == 0 {
+		return nil, nil
+	}
+	var cfg IPFilterConfig
+	if err := json.Unmarshal(configJSON, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal IP filter: %w", err)
+	}
+	return &cfg, nil
+}
+
+// UpdateIPFilter sets the IP filter of a user's webhook; nil removes it.
+func (db *DB) UpdateIPFilter(ctx context.Context, webhookID, userID string, cfg *IPFilterConfig) error {
+	var configJSON []byte
+	if cfg != nil {
+		var err error
+		if configJSON, err = json.Marshal(cfg); err != nil {
+			return fmt.Errorf("failed to marshal IP filter: %w", err)
+		}
+	}
+
+	query := `UPDATE webhooks SET ip_filter = $1 WHERE id = $2 AND user_id = $3`
+	result, err := db.ExecContext(ctx, query, configJSON, webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update IP filter: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SaveBlockedRequest logs a refused request. A refusal of the same address
+// for the same reason within BlockedAggregateWindow is counted on the
+// existing entry, so a sender retrying cannot add a row per attempt.
+func (db *DB) SaveBlockedRequest(ctx context.Context, b BlockedRequest) error {
+	query := `
+	WITH recent AS (
+		UPDATE blocked_requests SET
+			hits = hits + 1,
+			method = $4, path = $5, user_agent = $6,
+			last_seen_at = NOW()
+		WHERE id = (
+			SELECT id FROM blocked_requests
+			WHERE webhook_id = $1 AND reason = $2 AND remote_ip = $3
+				AND last_seen_at > NOW() - $7::bigint * INTERVAL '1 millisecond'
+			ORDER BY id DESC
+			LIMIT 1
+		)
+		RETURNING id
+	)
+	INSERT INTO blocked_requests (webhook_id, reason, remote_ip, method, path, user_agent)
+	SELECT $1, $2, $3, $4, $5, $6
+	WHERE NOT EXISTS (SELECT 1 FROM recent)`
+	if _, err := db.ExecContext(ctx, query, b.WebhookID, b.Reason, b.RemoteIP, b.Method, b.Path, b.UserAgent, BlockedAggregateWindow.Milliseconds()); err != nil {
+		return fmt.Errorf("failed to log blocked request for webhook %s: %w", b.WebhookID, err)
+	}
+	return nil
+}
+
+// PruneBlockedRequests deletes up to limit logged refusals last seen before
+// olderThan or beyond the keep most recent of their webhook. It returns how
+// many were deleted.
+func (db *DB) PruneBlockedRequests(ctx context.Context, olderThan time.Time, keep, limit int) (int, error) {
+	query := `
+	DELETE FROM blocked_requests
+	WHERE id IN (
+		SELECT id FROM (
+			SELECT id, last_seen_at,
+				row_number() OVER (PARTITION BY webhook_id ORDER BY last_seen_at DESC, id DESC) AS n
+			FROM blocked_requests
+		) b
+		WHERE b.last_seen_at < $1 OR b.n > $2
+		LIMIT $3
+	)`
+	result, err := db.ExecContext(ctx, query, olderThan, keep, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune blocked requests: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(deleted), nil
+}
+
+// GetBlockedRequests retrieves the most recent refused requests of a
+// webhook, most recently seen first. A non-empty reason only returns
+// requests refused for it.
+func (db *DB) GetBlockedRequests(ctx context.Context, webhookID, reason string, limit int) ([]BlockedRequest, error) {
+	query := `
+	SELECT id, webhook_id, reason, remote_ip, method, path, user_agent, hits, created_at, last_seen_at
+	FROM blocked_requests
+	WHERE webhook_id = $1 AND ($2 = '' OR reason = $2)
+	ORDER BY last_seen_at DESC, id DESC
+	LIMIT $3`
+
+	rows, err := db.QueryContext(ctx, query, webhookID, reason, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocked requests: %w", err)
+	}
+	defer rows.Close()
+
+	blocked := []BlockedRequest{}
+	for rows.Next() {
+		var b BlockedRequest
+		if err := rows.Scan(&b.ID, &b.WebhookID, &b.Reason, &b.RemoteIP, &b.Method, &b.Path, &b.UserAgent, &b.Hits, &b.CreatedAt, &b.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan blocked request row: %w", err)
+		}
+		blocked = append(blocked, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return blocked, nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// requestsChannel is the notification channel on which saved requests are
+// announced to every instance, with payloads "<webhook id>:<request id>".
+const requestsChannel = "hookinator_requests"
+
+// RequestNotification announces a request that was just saved.
+type RequestNotification struct {
+	WebhookID string
+	RequestID int64
+}
+
+// notifyRequests announces saved requests. Notifications are sent when the
+// transaction commits, so listeners can read the requests right away.
+func notifyRequests(ctx context.Context, tx *sql.Tx, requestIDs []int64, webhookIDs []string) error {
+	_, err := tx.ExecContext(ctx, `
+	SELECT pg_notify($3, s.webhook_id || ':' || s.request_id)
+	FROM unnest($1::bigint[], $2::text[]) AS s(request_id, webhook_id)`, requestIDs, webhookIDs, requestsChannel)
+	return err
+}
+
+// ListenRequests calls fn with every request saved by any instance until ctx
+// is done or the connection fails. It holds one connection of the pool
+// throughout, and fn blocks further notifications while it runs.
+func (db *DB) ListenRequests(ctx context.Context, fn func(RequestNotification)) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	var listenErr error
+	conn.Raw(func(driverConn any) error {
+		pgxConn := driverConn.(*stdlib.Conn).Conn()
+		if _, err := pgxConn.Exec(ctx, "LISTEN "+requestsChannel); err != nil {
+			listenErr = fmt.Errorf("failed to listen for requests: %w", err)
+		}
+		for listenErr == nil {
+			n, err := pgxConn.WaitForNotification(ctx)
+			if err != nil {
+				listenErr = fmt.Errorf("failed to wait for notification: %w", err)
+				break
+			}
+			webhookID, id, ok := strings.Cut(n.Payload, ":")
+			requestID, err := strconv.ParseInt(id, 10, 64)
+			if !ok || err != nil {
+				continue
+			}
+			fn(RequestNotification{WebhookID: webhookID, RequestID: requestID})
+		}
+		// The connection is still listening; keep it out of the pool.
+		return driver.ErrBadConn
+	})
+	return listenErr
+}
+
+// GetRequestsAfter retrieves up to limit requests of a webhook that come
+// after cursor in the order of (received_at, request_id), oldest first.
+func (db *DB) GetRequestsAfter(ctx context.Context, webhookID string, after RequestCursor, limit int) ([]WebhookRequest, error) {
+	query := requestSelect + `
+	WHERE r.webhook_id = $1 AND (r.received_at, r.request_id) > ($2, $3::bigint)
+	ORDER BY r.received_at, r.request_id
+	LIMIT $4`
+
+	rows, err := db.QueryContext(ctx, query, webhookID, after.ReceivedAt, after.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []WebhookRequest
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan request row: %w", err)
+		}
+		requests = append(requests, req)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return requests, nil
+}
+
+// GetRequestCursor returns the position of one of a webhook's requests in
+// the order of (received_at, request_id). It returns sql.ErrNoRows if the
+// webhook has no such request.
+func (db *DB) GetRequestCursor(ctx context.Context, webhookID string, requestID int64) (RequestCursor, error) {
+	var cursor RequestCursor
+	query := `SELECT received_at, request_id FROM requests WHERE webhook_id = $1 AND request_id = $2`
+	if err := db.QueryRowContext(ctx, query, webhookID, requestID).Scan(&cursor.ReceivedAt, &cursor.ID); err != nil {
+		if err == sql.ErrNoRows {
+			return RequestCursor{}, err
+		}
+		return RequestCursor{}, fmt.Errorf("failed to query request: %w", err)
+	}
+	return cursor, nil
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// DeliveryJob is a queued delivery of a captured request to a target URL,
+// together with the stored request it should replay. Jobs for the legacy
+// forward URL have no destination.
+type DeliveryJob struct {
+	ID            int64
+	RequestID     int64
+	WebhookID     string
+	DestinationID *int64
+	TargetURL     string
+	Attempts      int
+	Method        string
+	Path          string
+	Query         string
+	Headers       http.Header
+	Body          string
+
+	// Destination settings, resolved when the job is claimed.
+	Disabled     bool
+	Timeout      time.Duration
+	ExtraHeaders map[string]string
+}
+
+// ClaimDeliveryJobs leases up to limit due jobs for the caller. Jobs whose
+// lease has expired (e.g. because the worker holding them crashed) are
+// picked up again, which gives at-least-once delivery. Jobs of webhooks
+// whose forwarding is paused are left in the queue.
+func (db *DB) ClaimDeliveryJobs(ctx context.Context, limit int, lease time.Duration) ([]DeliveryJob, error) {
+	query := `
+	WITH claimed AS (
+		UPDATE delivery_queue
+		SET status = 'in_flight',
+			attempts = attempts + 1,
+			locked_until = NOW() + $2::bigint * INTERVAL '1 millisecond',
+			updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM delivery_queue
+			WHERE ((status = 'pending' AND next_attempt_at <= NOW())
+			   OR (status = 'in_flight' AND locked_until < NOW()))
+			  AND NOT EXISTS (
+				SELECT 1 FROM webhooks w
+				WHERE w.id = delivery_queue.webhook_id AND w.status = 'forwarding_paused'
+			  )
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, request_id, webhook_id, destination_id, target_url, attempts
+	)
+	SELECT c.id, c.request_id, c.webhook_id, c.destination_id, c.target_url, c.attempts,
+		r.method, r.path, r.query, r.headers, r.body, d.enabled, d.timeout_ms, d.headers
+	FROM claimed c
+	JOIN requests r ON r.request_id = c.request_id
+	LEFT JOIN destinations d ON d.id = c.destination_id`
+
+	rows, err := db.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim delivery jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []DeliveryJob
+	for rows.Next() {
+		var job DeliveryJob
+		var destinationID, timeoutMS sql.NullInt64
+		var enabled sql.NullBool
+		var headersJSON, extraHeadersJSON []byte
+		if err := rows.Scan(&job.ID, &job.RequestID, &job.WebhookID, &destinationID, &job.TargetURL, &job.Attempts,
+			&job.Method, &job.Path, &job.Query, &headersJSON, &job.Body, &enabled, &timeoutMS, &extraHeadersJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery job: %w", err)
+		}
+		if err := json.Unmarshal(headersJSON, &job.Headers); err != nil {
+			log.Printf("Warning: failed to unmarshal headers for delivery job %d: %v", job.ID, err)
+			job.Headers = nil
+		}
+		if destinationID.Valid {
+			job.DestinationID = &destinationID.Int64
+			job.Disabled = !enabled.Bool
+			job.Timeout = time.Duration(timeoutMS.Int64) * time.Millisecond
+			if err := json.Unmarshal(extraHeadersJSON, &job.ExtraHeaders); err != nil {
+				log.Printf("Warning: failed to unmarshal destination headers for delivery job %d: %v", job.ID, err)
+			}
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// CompleteDeliveryJob marks a job as successfully delivered.
+func (db *DB) CompleteDeliveryJob(ctx context.Context, jobID int64) error {
+	query := `
+	UPDATE delivery_queue
+	SET status = 'succeeded', locked_until = NULL, last_error = NULL, updated_at = NOW()
+	WHERE id = $1`
+	if _, err := db.ExecContext(ctx, query, jobID); err != nil {
+		return fmt.Errorf("failed to complete delivery job %d: %w", jobID, err)
+	}
+	return nil
+}
+
+// RetryDeliveryJob puts a job back in the queue to be attempted again at next.
+func (db *DB) RetryDeliveryJob(ctx context.Context, jobID int64, next time.Time, lastErr string) error {
+	query := `
+	UPDATE delivery_queue
+	SET status = 'pending', next_attempt_at = $2, locked_until = NULL, last_error = $3, updated_at = NOW()
+	WHERE id = $1`
+	if _, err := db.ExecContext(ctx, query, jobID, next, lastErr); err != nil {
+		return fmt.Errorf("failed to reschedule delivery job %d: %w", jobID, err)
+	}
+	return nil
+}
+
+// FailDeliveryJob marks a job as permanently failed after its last attempt.
+func (db *DB) FailDeliveryJob(ctx context.Context, jobID int64, lastErr string) error {
+	query := `
+	UPDATE delivery_queue
+	SET status = 'failed', locked_until = NULL, last_error = $2, updated_at = NOW()
+	WHERE id = $1`
+	if _, err := db.ExecContext(ctx, query, jobID, lastErr); err != nil {
+		return fmt.Errorf("failed to mark delivery job %d as failed: %w", jobID, err)
+	}
+	return nil
+}
+
+// ReleaseDeliveryJob returns a claimed job to the queue without counting the
+// attempt, e.g. when a worker is interrupted by shutdown.
+func (db *DB) ReleaseDeliveryJob(ctx context.Context, jobID int64) error {
+	query := `
+	UPDATE delivery_queue
+	SET status = 'pending', attempts = GREATEST(attempts - 1, 0), locked_until = NULL, updated_at = NOW()
+	WHERE id = $1 AND status = 'in_flight'`
+	if _, err := db.ExecContext(ctx, query, jobID); err != nil {
+		return fmt.Errorf("failed to release delivery job %d: %w", jobID, err)
+	}
+	return nil
+}
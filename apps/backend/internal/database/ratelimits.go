@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RateLimitConfig overrides the server's default ingestion limit for one
+// webhook.
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// TakeRateLimitToken refills the token bucket named key and takes a token if
+// one is available, in a single statement so concurrent instances never
+// overdraw it. It returns whether a token was taken and how many remain.
+func (db *DB) TakeRateLimitToken(ctx context.Context, key string, rate float64, burst int) (bool, float64, error) {
+	// A statement that waited for the row lock can start before the one
+	// holding it, so elapsed time is clamped at zero.
+	const refill = `LEAST($3::float8, rl.tokens + GREATEST(0, EXTRACT(EPOCH FROM (NOW() - rl.updated_at))) * $2::float8)`
+	query := `
+	INSERT INTO rate_limits AS rl (key, tokens, allowed, updated_at)
+	VALUES ($1, $3::float8 - 1, TRUE, NOW())
+	ON CONFLICT (key) DO UPDATE SET
+		tokens = CASE WHEN ` + refill + ` >= 1 THEN ` + refill + ` - 1 ELSE ` + refill + ` END,
+		allowed = ` + refill + ` >= 1,
+		updated_at = GREATEST(rl.updated_at, NOW())
+	RETURNING allowed, tokens`
+
+	var allowed bool
+	var tokens float64
+	if err := db.QueryRowContext(ctx, query, key, rate, burst).Scan(&allowed, &tokens); err != nil {
+		return false, 0, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return allowed, tokens, nil
+}
+
+// PruneRateLimits deletes buckets untouched for longer than idle.
+func (db *DB) PruneRateLimits(ctx context.Context, idle time.Duration) error {
+	query := `DELETE FROM rate_limits WHERE updated_at < NOW() - $1::bigint * INTERVAL '1 millisecond'`
+	if _, err := db.ExecContext(ctx, query, idle.Milliseconds()); err != nil {
+		return fmt.Errorf("failed to prune rate limits: %w", err)
+	}
+	return nil
+}
+
+// GetRateLimit retrieves the rate limit of a user's webhook, or nil if it
+// uses the server default.
+func (db *DB) GetRateLimit(ctx context.Context, webhookID, userID string) (*RateLimitConfig, error) {
+	var configJSON []byte
+	query := `SELECT rate_limit FROM webhooks WHERE id = $1 AND user_id = $2`
+	if err := db.QueryRowContext(ctx, query, webhookID, userID).Scan(&configJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to query rate limit: %w", err)
+	}
+	if len(configJSON) == 0 {
+		return nil, nil
+	}
+	var cfg RateLimitConfig
+	if err := json.Unmarshal(configJSON, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rate limit: %w", err)
+	}
+	return &cfg, nil
+}
+
+// UpdateRateLimit sets the rate limit of a user's webhook; nil restores the
+// server default.
+func (db *DB) UpdateRateLimit(ctx context.Context, webhookID, userID string, cfg *RateLimitConfig) error {
+	var configJSON []byte
+	if cfg != nil {
+		var err error
+		if configJSON, err = json.Marshal(cfg); err != nil {
+			return fmt.Errorf("failed to marshal rate limit: %w", err)
+		}
+	}
+
+	query := `UPDATE webhooks SET rate_limit = $1 WHERE id = $2 AND user_id = $3`
+	result, err := db.ExecContext(ctx, query, configJSON, webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update rate limit: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
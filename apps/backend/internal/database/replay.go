@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayFilter selects the captured requests of a bulk replay: those
+// matching the request filter and, if RequestIDs is set, among them.
+// The filter's After cursor is ignored.
+type ReplayFilter struct {
+	RequestFilter
+	RequestIDs []int64
+	Limit      int
+}
+
+// GetRequestsForReplay retrieves the captured requests matching f, oldest
+// first, so they can be re-sent in their original order. It returns
+// ErrInvalidJSONPath if Postgres rejects the filter's JSONPath.
+func (db *DB) GetRequestsForReplay(ctx context.Context, webhookID string, f ReplayFilter) ([]WebhookRequest, error) {
+	filter := f.RequestFilter
+	filter.After = nil
+	conds, args := requestConditions(filter, []interface{}{webhookID})
+	if len(f.RequestIDs) > 0 {
+		args = append(args, f.RequestIDs)
+		conds = append(conds, "r.request_id = ANY($"+strconv.Itoa(len(args))+"::bigint[])")
+	}
+	args = append(args, f.Limit)
+	query := requestSelect + `
+	WHERE ` + strings.Join(append([]string{"r.webhook_id = $1"}, conds...), " AND ") + `
+	ORDER BY r.received_at, r.request_id
+	LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		if jsonPathErr := invalidJSONPath(filter, err); jsonPathErr != nil {
+			return nil, jsonPathErr
+		}
+		return nil, fmt.Errorf("failed to query requests for replay: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []WebhookRequest
+	for rows.Next() {
+		req, err := scanRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan request row: %w", err)
+		}
+		requests = append(requests, req)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return requests, nil
+}
+
+// Bulk replay states. Only a running replay can be cancelled.
+const (
+	ReplayRunning     = "running"
+	ReplayFinished    = "finished"
+	ReplayCancelled   = "cancelled"
+	ReplayTimedOut    = "timed_out"
+	ReplayInterrupted = "interrupted"
+)
+
+// BulkReplay tracks the progress of a bulk replay. The row is shared so
+// that any instance can report on or cancel a replay another one runs.
+type BulkReplay struct {
+	ID        string    `json:"id"`
+	WebhookID string    `json:"webhook_id"`
+	Total     int       `json:"total"`
+	Sent      int       `json:"sent"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const bulkReplayColumns = `id, webhook_id, total, sent, status, created_at, updated_at`
+
+func scanBulkReplay(row rowScanner) (BulkReplay, error) {
+	var replay BulkReplay
+	err := row.Scan(&replay.ID, &replay.WebhookID, &replay.Total, &replay.Sent, &replay.Status, &replay.CreatedAt, &replay.UpdatedAt)
+	return replay, err
+}
+
+// CreateBulkReplay records a new running replay of total requests.
+func (db *DB) CreateBulkReplay(ctx context.Context, id, webhookID string, total int) (BulkReplay, error) {
+	query := `
+	INSERT INTO bulk_replays (id, webhook_id, total)
+	VALUES ($1, $2, $3)
+	RETURNING ` + bulkReplayColumns
+
+	replay, err := scanBulkReplay(db.QueryRowContext(ctx, query, id, webhookID, total))
+	if err != nil {
+		return BulkReplay{}, fmt.Errorf("failed to create bulk replay: %w", err)
+	}
+	return replay, nil
+}
+
+// GetBulkReplay retrieves one of a webhook's bulk replays. It returns
+// sql.ErrNoRows if the webhook has no such replay.
+func (db *DB) GetBulkReplay(ctx context.Context, webhookID, id string) (BulkReplay, error) {
+	query := `SELECT ` + bulkReplayColumns + ` FROM bulk_replays WHERE id = $1 AND webhook_id = $2`
+
+	replay, err := scanBulkReplay(db.QueryRowContext(ctx, query, id, webhookID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BulkReplay{}, err
+		}
+		return BulkReplay{}, fmt.Errorf("failed to query bulk replay: %w", err)
+	}
+	return replay, nil
+}
+
+// CancelBulkReplay asks a running replay of a webhook to stop and returns
+// its state; a replay that has already ended is returned unchanged. It
+// returns sql.ErrNoRows if the webhook has no such replay.
+func (db *DB) CancelBulkReplay(ctx context.Context, webhookID, id string) (BulkReplay, error) {
+	query := `
+	UPDATE bulk_replays
+	SET status = CASE WHEN status = $3 THEN $4 ELSE status END,
+		updated_at = CASE WHEN status = $3 THEN CURRENT_TIMESTAMP ELSE updated_at END
+	WHERE id = $1 AND webhook_id = $2
+	RETURNING ` + bulkReplayColumns
+
+	replay, err := scanBulkReplay(db.QueryRowContext(ctx, query, id, webhookID, ReplayRunning, ReplayCancelled))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return BulkReplay{}, err
+		}
+		return BulkReplay{}, fmt.Errorf("failed to cancel bulk replay: %w", err)
+	}
+	return replay, nil
+}
+
+// UpdateBulkReplay records how many requests a replay has sent and, if
+// status is not ReplayRunning, that it ended. The status of a replay that
+// already ended is kept. It returns the replay's status afterwards, so the
+// runner learns of a cancellation.
+func (db *DB) UpdateBulkReplay(ctx context.Context, id string, sent int, status string) (string, error) {
+	query := `
+	UPDATE bulk_replays
+	SET sent = $2,
+		status = CASE WHEN status = $4 THEN $3 ELSE status END,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = $1
+	RETURNING status`
+
+	var current string
+	if err := db.QueryRowContext(ctx, query, id, sent, status, ReplayRunning).Scan(&current); err != nil {
+		return "", fmt.Errorf("failed to update bulk replay: %w", err)
+	}
+	return current, nil
+}
+
+// GetDeliveryTargets returns the places a webhook currently forwards to,
+// the forward URL followed by its enabled destinations, as job templates
+// without a request.
+func (db *DB) GetDeliveryTargets(ctx context.Context, webhookID string) ([]DeliveryJob, error) {
+	query := `
+	SELECT NULL::bigint, forward_url, 0, '{}'::jsonb FROM webhooks
+	WHERE id = $1 AND forward_url IS NOT NULL AND forward_url <> ''
+	UNION ALL
+	SELECT id, url, timeout_ms, headers FROM (
+		SELECT id, url, timeout_ms, headers FROM destinations
+		WHERE webhook_id = $1 AND enabled
+		ORDER BY id
+	) d`
+
+	rows, err := db.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delivery targets: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []DeliveryJob
+	for rows.Next() {
+		var target DeliveryJob
+		var destinationID sql.NullInt64
+		var timeoutMS int64
+		var headersJSON []byte
+		if err := rows.Scan(&destinationID, &target.TargetURL, &timeoutMS, &headersJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery target: %w", err)
+		}
+		target.WebhookID = webhookID
+		if destinationID.Valid {
+			target.DestinationID = &destinationID.Int64
+		}
+		target.Timeout = time.Duration(timeoutMS) * time.Millisecond
+		if err := json.Unmarshal(headersJSON, &target.ExtraHeaders); err != nil {
+			log.Printf("Warning: failed to unmarshal destination headers: %v", err)
+		}
+		targets = append(targets, target)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,262 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RetentionPolicy limits how many captured requests a webhook keeps. In a
+// webhook's own policy, zero fields take the server default and NoLimit
+// removes a limit; in the server default, zero fields impose no limit.
+// Pinned requests and requests still waiting to be forwarded are never
+// pruned and do not count towards MaxCount or MaxBytes.
+type RetentionPolicy struct {
+	// MaxAgeSeconds prunes requests received longer ago.
+	MaxAgeSeconds int64 `json:"max_age_seconds,omitempty"`
+	// MaxCount keeps only the newest requests.
+	MaxCount int64 `json:"max_count,omitempty"`
+	// MaxBytes keeps only the newest requests whose bodies add up to at
+	// most this size.
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+}
+
+// NoLimit is the value of a limit a webhook removes from its policy.
+const NoLimit = -1
+
+// Unlimited reports whether the policy keeps everything.
+func (p RetentionPolicy) Unlimited() bool {
+	return p.MaxAgeSeconds <= 0 && p.MaxCount <= 0 && p.MaxBytes <= 0
+}
+
+// Or returns p with its unset limits taken from fallback. Limits set to
+// NoLimit are kept.
+func (p RetentionPolicy) Or(fallback RetentionPolicy) RetentionPolicy {
+	if p.MaxAgeSeconds == 0 {
+		p.MaxAgeSeconds = fallback.MaxAgeSeconds
+	}
+	if p.MaxCount == 0 {
+		p.MaxCount = fallback.MaxCount
+	}
+	if p.MaxBytes == 0 {
+		p.MaxBytes = fallback.MaxBytes
+	}
+	return p
+}
+
+// GetRetention retrieves the retention policy of a user's webhook, or nil if
+// it uses the server default.
+func (db *DB) GetRetention(ctx context.Context, webhookID, userID string) (*RetentionPolicy, error) {
+	var configJSON []byte
+	query := `SELECT retention FROM webhooks WHERE id = $1 AND user_id = $2`
+	if err := db.QueryRowContext(ctx, query, webhookID, userID).Scan(&configJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to query retention policy: %w", err)
+	}
+	if len(configJSON) == 0 {
+		return nil, nil
+	}
+	var policy RetentionPolicy
+	if err := json.Unmarshal(configJSON, &policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal retention policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// UpdateRetention sets the retention policy of a user's webhook; nil
+// restores the server default.
+func (db *DB) UpdateRetention(ctx context.Context, webhookID, userID string, policy *RetentionPolicy) error {
+	var configJSON []byte
+	if policy != nil {
+		var err error
+		if configJSON, err = json.Marshal(policy); err != nil {
+			return fmt.Errorf("failed to marshal retention policy: %w", err)
+		}
+	}
+
+	query := `UPDATE webhooks SET retention = $1 WHERE id = $2 AND user_id = $3`
+	result, err := db.ExecContext(ctx, query, configJSON, webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update retention policy: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// prunable selects the requests (aliased r) that retention may delete: those
+// neither pinned nor waiting to be forwarded. Deleting a request cascades to
+// its queued deliveries, so pruning must not outrun a backed-up forwarder.
+const prunable = `NOT r.pinned AND NOT EXISTS (
+	SELECT 1 FROM delivery_queue q
+	WHERE q.request_id = r.request_id AND q.status IN ('pending', 'in_flight'))`
+
+// GetRetentionPolicies returns the retention policy of every webhook that
+// has one of its own, keyed by webhook ID.
+func (db *DB) GetRetentionPolicies(ctx context.Context) (map[string]RetentionPolicy, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, retention FROM webhooks WHERE retention IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := make(map[string]RetentionPolicy)
+	for rows.Next() {
+		var id string
+		var configJSON []byte
+		if err := rows.Scan(&id, &configJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan retention policy: %w", err)
+		}
+		var policy RetentionPolicy
+		if err := json.Unmarshal(configJSON, &policy); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal retention policy of webhook %s: %w", id, err)
+		}
+		policies[id] = policy
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return policies, nil
+}
+
+// GetWebhooksWithRequests returns the IDs of the webhooks that have
+// captured requests.
+func (db *DB) GetWebhooksWithRequests(ctx context.Context) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id FROM webhooks w WHERE EXISTS (SELECT 1 FROM requests r WHERE r.webhook_id = w.id)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return ids, nil
+}
+
+// RetentionBoundary returns the newest of a webhook's prunable requests that
+// the count and size limits of policy exclude, or nil if they keep them all.
+// That request and every older prunable one are to be pruned.
+func (db *DB) RetentionBoundary(ctx context.Context, webhookID string, policy RetentionPolicy) (*RequestCursor, error) {
+	var boundary *RequestCursor
+	later := func(c RequestCursor) {
+		if boundary == nil || c.ReceivedAt.After(boundary.ReceivedAt) ||
+			c.ReceivedAt.Equal(boundary.ReceivedAt) && c.ID > boundary.ID {
+			boundary = &c
+		}
+	}
+
+	if policy.MaxCount > 0 {
+		var c RequestCursor
+		err := db.QueryRowContext(ctx, `
+		SELECT r.received_at, r.request_id FROM requests r
+		WHERE r.webhook_id = $1 AND `+prunable+`
+		ORDER BY r.received_at DESC, r.request_id DESC
+		OFFSET $2 LIMIT 1`, webhookID, policy.MaxCount).Scan(&c.ReceivedAt, &c.ID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to find count limit of webhook %s: %w", webhookID, err)
+		}
+		if err == nil {
+			later(c)
+		}
+	}
+
+	if policy.MaxBytes > 0 {
+		// The running total is computed row by row as the requests are read
+		// newest first along requests_webhook_received_idx, and the scan
+		// stops at the first request over the limit rather than summing the
+		// whole history. The outer query must not sort, or the window would
+		// be computed for every row before the first is returned.
+		var c RequestCursor
+		err := db.QueryRowContext(ctx, `
+		SELECT received_at, request_id FROM (
+			SELECT r.received_at, r.request_id,
+				sum(r.body_size) OVER (ORDER BY r.received_at DESC, r.request_id DESC
+					ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS total
+			FROM requests r
+			WHERE r.webhook_id = $1 AND `+prunable+`
+			ORDER BY r.received_at DESC, r.request_id DESC
+		) t
+		WHERE total > $2
+		LIMIT 1`, webhookID, policy.MaxBytes).Scan(&c.ReceivedAt, &c.ID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to find size limit of webhook %s: %w", webhookID, err)
+		}
+		if err == nil {
+			later(c)
+		}
+	}
+
+	return boundary, nil
+}
+
+// PruneRequests deletes up to limit of a webhook's prunable requests that
+// were received before olderThan, if it is set, or are no newer than
+// boundary, if it is set. It returns how many were deleted; fewer than limit
+// means none are left.
+func (db *DB) PruneRequests(ctx context.Context, webhookID string, olderThan time.Time, boundary *RequestCursor, limit int) (int, error) {
+	var cutoff sql.NullTime
+	if !olderThan.IsZero() {
+		cutoff = sql.NullTime{Time: olderThan, Valid: true}
+	}
+	var boundaryTime sql.NullTime
+	var boundaryID sql.NullInt64
+	if boundary != nil {
+		boundaryTime = sql.NullTime{Time: boundary.ReceivedAt, Valid: true}
+		boundaryID = sql.NullInt64{Int64: boundary.ID, Valid: true}
+	}
+
+	query := `
+	DELETE FROM requests
+	WHERE request_id IN (
+		SELECT r.request_id FROM requests r
+		WHERE r.webhook_id = $1 AND `+prunable+`
+			AND (r.received_at < $2 OR (r.received_at, r.request_id) <= ($3, $4::bigint))
+		LIMIT $5
+		FOR UPDATE SKIP LOCKED
+	)`
+	result, err := db.ExecContext(ctx, query, webhookID, cutoff, boundaryTime, boundaryID, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune requests of webhook %s: %w", webhookID, err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(deleted), nil
+}
+
+// SetRequestPinned pins or unpins one request of a webhook. It returns
+// sql.ErrNoRows if the webhook has no such request.
+func (db *DB) SetRequestPinned(ctx context.Context, webhookID string, requestID int64, pinned bool) error {
+	result, err := db.ExecContext(ctx, `UPDATE requests SET pinned = $1 WHERE request_id = $2 AND webhook_id = $3`, pinned, requestID, webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to update request: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaConfig validates incoming bodies against a JSON Schema. Action is
+// what happens to payloads that fail: "record" (the default) only stores
+// the errors, "reject" answers 422 and "skip_forwarding" captures the
+// request without delivering it.
+type SchemaConfig struct {
+	Schema json.RawMessage `json:"schema"`
+	Action string          `json:"action,omitempty"`
+}
+
+// ValidationError is one way a captured body fails its webhook's schema.
+// Path is the JSON pointer of the offending value.
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// GetSchemaConfig retrieves the schema config of a user's webhook, or nil if
+// bodies are not validated.
+func (db *DB) GetSchemaConfig(ctx context.Context, webhookID, userID string) (*SchemaConfig, error) {
+	var configJSON []byte
+	query := `SELECT schema_config FROM webhooks WHERE id = $1 AND user_id = $2`
+	if err := db.QueryRowContext(ctx, query, webhookID, userID).Scan(&configJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to query schema config: %w", err)
+	}
+	if len(configJSON) == 0 {
+		return nil, nil
+	}
+	var cfg SchemaConfig
+	if err := json.Unmarshal(configJSON, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schema config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// UpdateSchemaConfig sets the schema config of a user's webhook; nil stops
+// validation.
+func (db *DB) UpdateSchemaConfig(ctx context.Context, webhookID, userID string, cfg *SchemaConfig) error {
+	var configJSON []byte
+	if cfg != nil {
+		var err error
+		if configJSON, err = json.Marshal(cfg); err != nil {
+			return fmt.Errorf("failed to marshal schema config: %w", err)
+		}
+	}
+
+	query := `UPDATE webhooks SET schema_config = $1 WHERE id = $2 AND user_id = $3`
+	result, err := db.ExecContext(ctx, query, configJSON, webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update schema config: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
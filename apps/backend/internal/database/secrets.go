@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Purposes a webhook secret is used for.
+const (
+	// SecretVerification secrets are shared with a provider and check the
+	// signatures of incoming requests.
+	SecretVerification = "verification"
+	// SecretSigning secrets sign the requests hookinator forwards.
+	SecretSigning = "signing"
+)
+
+// ErrSecretRevealed is returned when revealing a secret a second time.
+var ErrSecretRevealed = errors.New("secret already revealed")
+
+// WebhookSecret is an encrypted secret of a webhook. A secret is active
+// until it is revoked or, after being rotated out, its expiry passes.
+type WebhookSecret struct {
+	ID         int64  `json:"id"`
+	WebhookID  string `json:"webhook_id"`
+	Purpose    string `json:"purpose"`
+	Ciphertext []byte `json:"-"`
+	// Hint is the end of the secret, for telling secrets apart.
+	Hint       string     `json:"hint"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevealedAt *time.Time `json:"revealed_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+const secretColumns = `id, webhook_id, purpose, ciphertext, hint, created_at, expires_at, revealed_at, revoked_at`
+
+func scanSecret(row rowScanner) (WebhookSecret, error) {
+	var s WebhookSecret
+	var expiresAt, revealedAt, revokedAt sql.NullTime
+	if err := row.Scan(&s.ID, &s.WebhookID, &s.Purpose, &s.Ciphertext, &s.Hint, &s.CreatedAt, &expiresAt, &revealedAt, &revokedAt); err != nil {
+		return WebhookSecret{}, err
+	}
+	if expiresAt.Valid {
+		s.ExpiresAt = &expiresAt.Time
+	}
+	if revealedAt.Valid {
+		s.RevealedAt = &revealedAt.Time
+	}
+	if revokedAt.Valid {
+		s.RevokedAt = &revokedAt.Time
+	}
+	return s, nil
+}
+
+func (db *DB) querySecrets(ctx context.Context, query string, args ...interface{}) ([]WebhookSecret, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query secrets: %w", err)
+	}
+	defer rows.Close()
+
+	list := []WebhookSecret{}
+	for rows.Next() {
+		s, err := scanSecret(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan secret row: %w", err)
+		}
+		list = append(list, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return list, nil
+}
+
+// GetSecrets retrieves every secret of a webhook, including revoked and
+// expired ones, newest first.
+func (db *DB) GetSecrets(ctx context.Context, webhookID string) ([]WebhookSecret, error) {
+	query := `SELECT ` + secretColumns + ` FROM webhook_secrets WHERE webhook_id = $1 ORDER BY id DESC`
+	return db.querySecrets(ctx, query, webhookID)
+}
+
+// GetActiveSecrets retrieves the secrets of a webhook currently in use for a
+// purpose, newest first.
+func (db *DB) GetActiveSecrets(ctx context.Context, webhookID, purpose string) ([]WebhookSecret, error) {
+	query := `
+	SELECT ` + secretColumns + ` FROM webhook_secrets
+	WHERE webhook_id = $1 AND purpose = $2 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	ORDER BY id DESC`
+	return db.querySecrets(ctx, query, webhookID, purpose)
+}
+
+// AddSecret stores a new secret. Secrets already active for the same purpose
+// stay valid for the overlap and then expire; with no overlap they expire
+// immediately.
+func (db *DB) AddSecret(ctx context.Context, s WebhookSecret, overlap time.Duration) (WebhookSecret, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return WebhookSecret{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	expire := `
+	UPDATE webhook_secrets
+	SET expires_at = NOW() + $3::bigint * INTERVAL '1 millisecond'
+	WHERE webhook_id = $1 AND purpose = $2 AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > NOW() + $3::bigint * INTERVAL '1 millisecond')`
+	if _, err := tx.ExecContext(ctx, expire, s.WebhookID, s.Purpose, overlap.Milliseconds()); err != nil {
+		return WebhookSecret{}, fmt.Errorf("failed to expire previous secrets: %w", err)
+	}
+
+	insert := `
+	INSERT INTO webhook_secrets (webhook_id, purpose, ciphertext, hint, revealed_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + secretColumns
+	created, err := scanSecret(tx.QueryRowContext(ctx, insert, s.WebhookID, s.Purpose, s.Ciphertext, s.Hint, s.RevealedAt))
+	if err != nil {
+		return WebhookSecret{}, fmt.Errorf("failed to store secret: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return WebhookSecret{}, fmt.Errorf("failed to commit secret: %w", err)
+	}
+	return created, nil
+}
+
+// RevealSecret decrypts a secret with open and marks it as revealed. A
+// secret that fails to decrypt stays unrevealed. It returns
+// ErrSecretRevealed if it was revealed before, and sql.ErrNoRows if it does
+// not exist or was revoked.
+func (db *DB) RevealSecret(ctx context.Context, webhookID string, secretID int64, open func(ciphertext []byte) (string, error)) (WebhookSecret, string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return WebhookSecret{}, "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The row lock keeps two concurrent reveals from both succeeding.
+	query := `SELECT ` + secretColumns + ` FROM webhook_secrets WHERE id = $1 AND webhook_id = $2 AND revoked_at IS NULL FOR UPDATE`
+	s, err := scanSecret(tx.QueryRowContext(ctx, query, secretID, webhookID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return WebhookSecret{}, "", err
+		}
+		return WebhookSecret{}, "", fmt.Errorf("failed to query secret: %w", err)
+	}
+	if s.RevealedAt != nil {
+		return WebhookSecret{}, "", ErrSecretRevealed
+	}
+
+	plaintext, err := open(s.Ciphertext)
+	if err != nil {
+		return WebhookSecret{}, "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `UPDATE webhook_secrets SET revealed_at = $1 WHERE id = $2`, now, secretID); err != nil {
+		return WebhookSecret{}, "", fmt.Errorf("failed to reveal secret: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return WebhookSecret{}, "", fmt.Errorf("failed to commit secret reveal: %w", err)
+	}
+	s.RevealedAt = &now
+	return s, plaintext, nil
+}
+
+// RevokeSecret stops a secret from being used immediately. It returns
+// sql.ErrNoRows if the secret does not exist or was already revoked.
+func (db *DB) RevokeSecret(ctx context.Context, webhookID string, secretID int64) error {
+	query := `UPDATE webhook_secrets SET revoked_at = NOW() WHERE id = $1 AND webhook_id = $2 AND revoked_at IS NULL`
+	result, err := db.ExecContext(ctx, query, secretID, webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke secret: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// SecretHint returns the last characters of a secret for display.
+func SecretHint(secret string) string {
+	if len(secret) <= 8 {
+		return ""
+	}
+	return secret[len(secret)-4:]
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Statuses a webhook can be in.
+const (
+	// WebhookActive captures and forwards requests.
+	WebhookActive = "active"
+	// WebhookCaptureOnly captures requests without queueing deliveries.
+	WebhookCaptureOnly = "capture_only"
+	// WebhookForwardingPaused captures requests and queues their deliveries,
+	// which are held until the webhook is active again.
+	WebhookForwardingPaused = "forwarding_paused"
+	// WebhookDisabled refuses requests so that providers retry them later.
+	// Deliveries already queued still go out.
+	WebhookDisabled = "disabled"
+)
+
+// WebhookStatus is whether a webhook captures and forwards requests, and
+// how a disabled webhook answers senders.
+type WebhookStatus struct {
+	Status string `json:"status"`
+	// DisabledStatusCode is 503 or 410.
+	DisabledStatusCode int `json:"disabled_status_code"`
+	// RetryAfterSeconds is sent as Retry-After while disabled.
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+}
+
+// GetWebhookStatus retrieves the status of a user's webhook.
+func (db *DB) GetWebhookStatus(ctx context.Context, webhookID, userID string) (WebhookStatus, error) {
+	var s WebhookStatus
+	query := `SELECT status, disabled_status_code, retry_after_seconds FROM webhooks WHERE id = $1 AND user_id = $2`
+	if err := db.QueryRowContext(ctx, query, webhookID, userID).Scan(&s.Status, &s.DisabledStatusCode, &s.RetryAfterSeconds); err != nil {
+		if err == sql.ErrNoRows {
+			return WebhookStatus{}, err
+		}
+		return WebhookStatus{}, fmt.Errorf("failed to query webhook status: %w", err)
+	}
+	return s, nil
+}
+
+// UpdateWebhookStatus sets the status of a user's webhook.
+func (db *DB) UpdateWebhookStatus(ctx context.Context, webhookID, userID string, s WebhookStatus) error {
+	query := `
+	UPDATE webhooks SET status = $1, disabled_status_code = $2, retry_after_seconds = $3
+	WHERE id = $4 AND user_id = $5`
+	result, err := db.ExecContext(ctx, query, s.Status, s.DisabledStatusCode, s.RetryAfterSeconds, webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CreateStreamTicket stores a ticket that lets its holder open one stream of
+// a webhook as the user until expiresAt. Only the hash of the ticket is kept.
+func (db *DB) CreateStreamTicket(ctx context.Context, ticketHash, userID, webhookID string, expiresAt time.Time) error {
+	// Tickets that were never used are cleared out as new ones are issued.
+	if _, err := db.ExecContext(ctx, `DELETE FROM stream_tickets WHERE expires_at < NOW()`); err != nil {
+		return fmt.Errorf("failed to delete expired stream tickets: %w", err)
+	}
+	query := `INSERT INTO stream_tickets (ticket_hash, user_id, webhook_id, expires_at) VALUES ($1, $2, $3, $4)`
+	if _, err := db.ExecContext(ctx, query, ticketHash, userID, webhookID, expiresAt); err != nil {
+		return fmt.Errorf("failed to create stream ticket: %w", err)
+	}
+	return nil
+}
+
+// RedeemStreamTicket uses up a ticket for a stream of a webhook and returns
+// the user it was issued to. It returns sql.ErrNoRows if the ticket is
+// unknown, expired, already used or issued for another webhook.
+func (db *DB) RedeemStreamTicket(ctx context.Context, ticketHash, webhookID string) (string, error) {
+	var userID string
+	query := `
+	DELETE FROM stream_tickets
+	WHERE ticket_hash = $1 AND webhook_id = $2 AND expires_at > NOW()
+	RETURNING user_id`
+	if err := db.QueryRowContext(ctx, query, ticketHash, webhookID).Scan(&userID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to redeem stream ticket: %w", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,323 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ScenarioStarted is the state every scenario begins in.
+const ScenarioStarted = "Started"
+
+// Stub is a WireMock-style rule: when an incoming request matches, the
+// configured response (or fault) is returned instead of the webhook's own.
+// Stubs are tried in ascending priority, then creation order.
+type Stub struct {
+	ID        int64          `json:"id"`
+	WebhookID string         `json:"webhook_id"`
+	Name      string         `json:"name"`
+	Priority  int            `json:"priority"`
+	Enabled   bool           `json:"enabled"`
+	Match     StubMatch      `json:"match"`
+	Response  ResponseConfig `json:"response"`
+	Fault     *StubFault     `json:"fault,omitempty"`
+
+	// Scenario makes the stub part of a state machine: it only matches while
+	// the scenario is in RequiredState (any state if empty), and moves the
+	// scenario to NewState after TransitionAfter matches (default 1).
+	Scenario        string `json:"scenario,omitempty"`
+	RequiredState   string `json:"required_state,omitempty"`
+	NewState        string `json:"new_state,omitempty"`
+	TransitionAfter int    `json:"transition_after,omitempty"`
+
+	Hits      int64     `json:"hits"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StubMatch lists the conditions a request must meet; empty ones match
+// anything.
+type StubMatch struct {
+	Method  string                  `json:"method,omitempty"`
+	Path    *ValueMatcher           `json:"path,omitempty"`
+	Headers map[string]ValueMatcher `json:"headers,omitempty"`
+	Query   map[string]ValueMatcher `json:"query,omitempty"`
+	Body    []BodyMatcher           `json:"body,omitempty"`
+}
+
+// ValueMatcher tests a single string value. Exactly one test should be set.
+type ValueMatcher struct {
+	EqualTo  *string `json:"equalTo,omitempty"`
+	Contains string  `json:"contains,omitempty"`
+	Matches  string  `json:"matches,omitempty"`
+	Absent   bool    `json:"absent,omitempty"`
+}
+
+// BodyMatcher tests the value at a JSON path of the request body.
+type BodyMatcher struct {
+	Path string `json:"path"`
+	ValueMatcher
+}
+
+// StubFault simulates a misbehaving upstream instead of responding normally.
+type StubFault struct {
+	// Type is one of "timeout", "connection_reset", "empty_response" or
+	// "error_burst".
+	Type string `json:"type"`
+	// DurationMS is how long a timeout hangs before the connection is closed.
+	DurationMS int `json:"duration_ms,omitempty"`
+	// An error burst answers the first Burst of every Period matches with
+	// Status (default 503); with no Period only the first Burst fail.
+	Status int `json:"status,omitempty"`
+	Burst  int `json:"burst,omitempty"`
+	Period int `json:"period,omitempty"`
+}
+
+// Scenario is the current state of a stub state machine.
+type Scenario struct {
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	StateHits int       `json:"state_hits"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const stubColumns = `id, webhook_id, name, priority, enabled, match, response, fault,
+	scenario, required_state, new_state, transition_after, hits, created_at, updated_at`
+
+func scanStub(row rowScanner) (Stub, error) {
+	var s Stub
+	var matchJSON, responseJSON, faultJSON []byte
+	err := row.Scan(&s.ID, &s.WebhookID, &s.Name, &s.Priority, &s.Enabled, &matchJSON, &responseJSON, &faultJSON,
+		&s.Scenario, &s.RequiredState, &s.NewState, &s.TransitionAfter, &s.Hits, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Stub{}, err
+		}
+		return Stub{}, fmt.Errorf("failed to scan stub row: %w", err)
+	}
+	if err := json.Unmarshal(matchJSON, &s.Match); err != nil {
+		return Stub{}, fmt.Errorf("failed to unmarshal stub match: %w", err)
+	}
+	if err := json.Unmarshal(responseJSON, &s.Response); err != nil {
+		return Stub{}, fmt.Errorf("failed to unmarshal stub response: %w", err)
+	}
+	if len(faultJSON) > 0 {
+		if err := json.Unmarshal(faultJSON, &s.Fault); err != nil {
+			return Stub{}, fmt.Errorf("failed to unmarshal stub fault: %w", err)
+		}
+	}
+	return s, nil
+}
+
+func stubArgs(s Stub) ([]interface{}, error) {
+	matchJSON, err := json.Marshal(s.Match)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stub match: %w", err)
+	}
+	responseJSON, err := json.Marshal(s.Response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stub response: %w", err)
+	}
+	var faultJSON []byte
+	if s.Fault != nil {
+		if faultJSON, err = json.Marshal(s.Fault); err != nil {
+			return nil, fmt.Errorf("failed to marshal stub fault: %w", err)
+		}
+	}
+	return []interface{}{s.WebhookID, s.Name, s.Priority, s.Enabled, matchJSON, responseJSON, faultJSON,
+		s.Scenario, s.RequiredState, s.NewState, s.TransitionAfter}, nil
+}
+
+// GetStubs retrieves the stubs of a webhook in matching order.
+func (db *DB) GetStubs(ctx context.Context, webhookID string) ([]Stub, error) {
+	query := `SELECT ` + stubColumns + ` FROM stubs WHERE webhook_id = $1 ORDER BY priority, id`
+
+	rows, err := db.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stubs: %w", err)
+	}
+	defer rows.Close()
+
+	stubs := []Stub{}
+	for rows.Next() {
+		s, err := scanStub(rows)
+		if err != nil {
+			return nil, err
+		}
+		stubs = append(stubs, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return stubs, nil
+}
+
+// CreateStub adds a stub to a webhook and returns it.
+func (db *DB) CreateStub(ctx context.Context, s Stub) (Stub, error) {
+	args, err := stubArgs(s)
+	if err != nil {
+		return Stub{}, err
+	}
+
+	query := `
+	INSERT INTO stubs (webhook_id, name, priority, enabled, match, response, fault,
+		scenario, required_state, new_state, transition_after)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING ` + stubColumns
+
+	created, err := scanStub(db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return Stub{}, fmt.Errorf("failed to create stub: %w", err)
+	}
+	return created, nil
+}
+
+// UpdateStub replaces a stub's definition, keeping its hit count. It returns
+// sql.ErrNoRows if the stub does not belong to the webhook.
+func (db *DB) UpdateStub(ctx context.Context, s Stub) (Stub, error) {
+	args, err := stubArgs(s)
+	if err != nil {
+		return Stub{}, err
+	}
+
+	query := `
+	UPDATE stubs
+	SET name = $2, priority = $3, enabled = $4, match = $5, response = $6, fault = $7,
+		scenario = $8, required_state = $9, new_state = $10, transition_after = $11, updated_at = NOW()
+	WHERE webhook_id = $1 AND id = $12
+	RETURNING ` + stubColumns
+
+	updated, err := scanStub(db.QueryRowContext(ctx, query, append(args, s.ID)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Stub{}, err
+		}
+		return Stub{}, fmt.Errorf("failed to update stub: %w", err)
+	}
+	return updated, nil
+}
+
+// DeleteStub removes a stub. It returns sql.ErrNoRows if the stub does not
+// belong to the webhook.
+func (db *DB) DeleteStub(ctx context.Context, webhookID string, stubID int64) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM stubs WHERE id = $1 AND webhook_id = $2`, stubID, webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to delete stub: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetScenarios retrieves the scenario states of a webhook. Scenarios that
+// have not been entered yet are not listed and are in ScenarioStarted.
+func (db *DB) GetScenarios(ctx context.Context, webhookID string) ([]Scenario, error) {
+	query := `SELECT name, state, state_hits, updated_at FROM scenarios WHERE webhook_id = $1 ORDER BY name`
+
+	rows, err := db.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scenarios: %w", err)
+	}
+	defer rows.Close()
+
+	scenarios := []Scenario{}
+	for rows.Next() {
+		var sc Scenario
+		if err := rows.Scan(&sc.Name, &sc.State, &sc.StateHits, &sc.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan scenario row: %w", err)
+		}
+		scenarios = append(scenarios, sc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return scenarios, nil
+}
+
+// ResetScenarios puts every scenario of a webhook back in ScenarioStarted and
+// zeroes the stub hit counters that error bursts count against.
+func (db *DB) ResetScenarios(ctx context.Context, webhookID string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM scenarios WHERE webhook_id = $1`, webhookID); err != nil {
+		return fmt.Errorf("failed to reset scenarios: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE stubs SET hits = 0 WHERE webhook_id = $1`, webhookID); err != nil {
+		return fmt.Errorf("failed to reset stub hits: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit scenario reset: %w", err)
+	}
+	return nil
+}
+
+// RecordStubHit counts a match of a stub and advances its scenario. It
+// returns the stub's hit count including this one.
+func (db *DB) RecordStubHit(ctx context.Context, s Stub) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var hits int64
+	if err := tx.QueryRowContext(ctx, `UPDATE stubs SET hits = hits + 1 WHERE id = $1 RETURNING hits`, s.ID).Scan(&hits); err != nil {
+		return 0, fmt.Errorf("failed to record hit of stub %d: %w", s.ID, err)
+	}
+
+	if s.Scenario != "" && s.NewState != "" {
+		upsert := `
+		INSERT INTO scenarios (webhook_id, name, state) VALUES ($1, $2, $3)
+		ON CONFLICT (webhook_id, name) DO NOTHING`
+		if _, err := tx.ExecContext(ctx, upsert, s.WebhookID, s.Scenario, ScenarioStarted); err != nil {
+			return 0, fmt.Errorf("failed to create scenario %s: %w", s.Scenario, err)
+		}
+
+		var state string
+		var stateHits int
+		query := `SELECT state, state_hits FROM scenarios WHERE webhook_id = $1 AND name = $2 FOR UPDATE`
+		if err := tx.QueryRowContext(ctx, query, s.WebhookID, s.Scenario).Scan(&state, &stateHits); err != nil {
+			return 0, fmt.Errorf("failed to lock scenario %s: %w", s.Scenario, err)
+		}
+
+		// Another request may have moved the scenario on since matching.
+		if s.RequiredState == "" || s.RequiredState == state {
+			stateHits++
+			transitionAfter := s.TransitionAfter
+			if transitionAfter < 1 {
+				transitionAfter = 1
+			}
+			if stateHits >= transitionAfter {
+				state, stateHits = s.NewState, 0
+			}
+			update := `UPDATE scenarios SET state = $3, state_hits = $4, updated_at = NOW() WHERE webhook_id = $1 AND name = $2`
+			if _, err := tx.ExecContext(ctx, update, s.WebhookID, s.Scenario, state, stateHits); err != nil {
+				return 0, fmt.Errorf("failed to advance scenario %s: %w", s.Scenario, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit stub hit: %w", err)
+	}
+	return hits, nil
+}
@@ -0,0 +1,405 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// foreignKeyViolation is the Postgres SQLSTATE for a foreign key violation.
+const foreignKeyViolation = "23503"
+
+// ErrWebhookGone is returned for webhooks that existed but were deleted.
+var ErrWebhookGone = errors.New("webhook deleted")
+
+// ErrWebhookExpired is returned for webhooks that were deleted because they
+// expired.
+var ErrWebhookExpired = errors.New("webhook expired")
+
+// Webhook is the configuration of a webhook as needed at ingestion time.
+type Webhook struct {
+	ID         string
+	UserID     string
+	ForwardURL string
+	Name       string
+	SourceType string
+	CreatedAt  time.Time
+	// ExpiresAt is when an ephemeral webhook stops accepting requests and is
+	// deleted; nil means never.
+	ExpiresAt *time.Time
+	// Status is whether the webhook captures and forwards requests.
+	Status WebhookStatus
+	// Response is what senders get back; nil means the default
+	// acknowledgement.
+	Response *ResponseConfig
+	// Verification configures signature checks; nil means none.
+	Verification *VerificationConfig
+	// Dedup configures duplicate detection; nil means none.
+	Dedup *DedupConfig
+	// IPFilter restricts client addresses; nil allows all.
+	IPFilter *IPFilterConfig
+	// RateLimit overrides the default ingestion limit; nil uses it.
+	RateLimit *RateLimitConfig
+	// IngestAuth requires senders to authenticate; nil means public.
+	IngestAuth *IngestAuthConfig
+	// Schema validates incoming bodies; nil means they are not checked.
+	Schema *SchemaConfig
+	// EventType overrides where the event type is found; nil uses the
+	// built-in rule of the source type.
+	EventType *EventTypeConfig
+}
+
+// ResponseConfig describes the response returned to a sender. Headers and
+// Body are Go text/template strings rendered against the incoming request.
+type ResponseConfig struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+	DelayMS int               `json:"delay_ms,omitempty"`
+}
+
+// GetWebhook retrieves a webhook by ID regardless of owner. It returns
+// sql.ErrNoRows for IDs that never existed, ErrWebhookExpired for webhooks
+// deleted on expiry and ErrWebhookGone for other deleted ones.
+func (db *DB) GetWebhook(ctx context.Context, webhookID string) (*Webhook, error) {
+	query := `
+	SELECT id, user_id, COALESCE(forward_url, ''), COALESCE(name, ''), COALESCE(source_type, ''), created_at,
+		expires_at, status, disabled_status_code, retry_after_seconds,
+		response_config, verification_config, dedup_config, ip_filter, rate_limit, ingest_auth, schema_config, event_type_config
+	FROM webhooks
+	WHERE id = $1`
+
+	var wh Webhook
+	var expiresAt sql.NullTime
+	var responseJSON, verificationJSON, dedupJSON, ipFilterJSON, rateLimitJSON, ingestAuthJSON, schemaJSON, eventTypeJSON []byte
+	err := db.QueryRowContext(ctx, query, webhookID).Scan(&wh.ID, &wh.UserID, &wh.ForwardURL, &wh.Name, &wh.SourceType, &wh.CreatedAt,
+		&expiresAt, &wh.Status.Status, &wh.Status.DisabledStatusCode, &wh.Status.RetryAfterSeconds,
+		&responseJSON, &verificationJSON, &dedupJSON, &ipFilterJSON, &rateLimitJSON, &ingestAuthJSON, &schemaJSON, &eventTypeJSON)
+	if err == nil {
+		if expiresAt.Valid {
+			wh.ExpiresAt = &expiresAt.Time
+		}
+		if len(responseJSON) > 0 {
+			if err := json.Unmarshal(responseJSON, &wh.Response); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal response config: %w", err)
+			}
+		}
+		if len(verificationJSON) > 0 {
+			if err := json.Unmarshal(verificationJSON, &wh.Verification); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal verification config: %w", err)
+			}
+		}
+		if len(dedupJSON) > 0 {
+			if err := json.Unmarshal(dedupJSON, &wh.Dedup); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal dedup config: %w", err)
+			}
+		}
+		if len(ipFilterJSON) > 0 {
+			if err := json.Unmarshal(ipFilterJSON, &wh.IPFilter); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal IP filter: %w", err)
+			}
+		}
+		if len(rateLimitJSON) > 0 {
+			if err := json.Unmarshal(rateLimitJSON, &wh.RateLimit); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal rate limit: %w", err)
+			}
+		}
+		if len(ingestAuthJSON) > 0 {
+			if err := json.Unmarshal(ingestAuthJSON, &wh.IngestAuth); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal ingest auth: %w", err)
+			}
+		}
+		if len(schemaJSON) > 0 {
+			if err := json.Unmarshal(schemaJSON, &wh.Schema); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal schema config: %w", err)
+			}
+		}
+		if len(eventTypeJSON) > 0 {
+			if err := json.Unmarshal(eventTypeJSON, &wh.EventType); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal event type config: %w", err)
+			}
+		}
+		return &wh, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to query webhook: %w", err)
+	}
+
+	var expired bool
+	if err := db.QueryRowContext(ctx, `SELECT expired FROM deleted_webhooks WHERE id = $1`, webhookID).Scan(&expired); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to query deleted webhooks: %w", err)
+	}
+	if expired {
+		return nil, ErrWebhookExpired
+	}
+	return nil, ErrWebhookGone
+}
+
+// GetWebhookResponse retrieves the configured response of a user's webhook,
+// or nil if it uses the default.
+func (db *DB) GetWebhookResponse(ctx context.Context, webhookID, userID string) (*ResponseConfig, error) {
+	var responseJSON []byte
+	query := `SELECT response_config FROM webhooks WHERE id = $1 AND user_id = $2`
+	if err := db.QueryRowContext(ctx, query, webhookID, userID).Scan(&responseJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to query response config: %w", err)
+	}
+	if len(responseJSON) == 0 {
+		return nil, nil
+	}
+	var cfg ResponseConfig
+	if err := json.Unmarshal(responseJSON, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// UpdateWebhookResponse sets the configured response of a user's webhook;
+// nil restores the default.
+func (db *DB) UpdateWebhookResponse(ctx context.Context, webhookID, userID string, cfg *ResponseConfig) error {
+	var responseJSON []byte
+	if cfg != nil {
+		var err error
+		if responseJSON, err = json.Marshal(cfg); err != nil {
+			return fmt.Errorf("failed to marshal response config: %w", err)
+		}
+	}
+
+	query := `UPDATE webhooks SET response_config = $1 WHERE id = $2 AND user_id = $3`
+	result, err := db.ExecContext(ctx, query, responseJSON, webhookID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update response config: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Rejection counts the requests refused for a webhook ID for one reason.
+type Rejection struct {
+	WebhookID      string    `json:"webhook_id"`
+	Reason         string    `json:"reason"`
+	Count          int64     `json:"count"`
+	LastRejectedAt time.Time `json:"last_rejected_at"`
+}
+
+// MaxUnknownRejections is how many counters of IDs that never existed are
+// kept; beyond it the least recently rejected are dropped.
+const MaxUnknownRejections = 1000
+
+// RecordRejections adds counts of refused requests, at most one per webhook
+// ID and reason. Counts for IDs that never existed go to a separate table
+// holding the MaxUnknownRejections most recent ones.
+func (db *DB) RecordRejections(ctx context.Context, rejections []Rejection) error {
+	if len(rejections) == 0 {
+		return nil
+	}
+	ids := make([]string, len(rejections))
+	reasons := make([]string, len(rejections))
+	counts := make([]int64, len(rejections))
+	times := make([]time.Time, len(rejections))
+	for i, rj := range rejections {
+		ids[i], reasons[i], counts[i], times[i] = rj.WebhookID, rj.Reason, rj.Count, rj.LastRejectedAt
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+	WITH batch AS (
+		SELECT b.webhook_id, b.reason, b.count, b.last_rejected_at,
+			EXISTS (SELECT 1 FROM webhooks WHERE id = b.webhook_id)
+				OR EXISTS (SELECT 1 FROM deleted_webhooks WHERE id = b.webhook_id) AS known
+		FROM unnest($1::text[], $2::text[], $3::bigint[], $4::timestamptz[])
+			AS b(webhook_id, reason, count, last_rejected_at)
+	), known AS (
+		INSERT INTO rejections (webhook_id, reason, count, last_rejected_at)
+		SELECT webhook_id, reason, count, last_rejected_at FROM batch WHERE known
+		ON CONFLICT (webhook_id, reason) DO UPDATE SET
+			count = rejections.count + EXCLUDED.count,
+			last_rejected_at = GREATEST(rejections.last_rejected_at, EXCLUDED.last_rejected_at)
+	)
+	INSERT INTO unknown_rejections (webhook_id, reason, count, last_rejected_at)
+	SELECT webhook_id, reason, count, last_rejected_at FROM batch WHERE NOT known
+	ON CONFLICT (webhook_id, reason) DO UPDATE SET
+		count = unknown_rejections.count + EXCLUDED.count,
+		last_rejected_at = GREATEST(unknown_rejections.last_rejected_at, EXCLUDED.last_rejected_at)`
+	if _, err := tx.ExecContext(ctx, query, ids, reasons, counts, times); err != nil {
+		return fmt.Errorf("failed to record rejections: %w", err)
+	}
+
+	trim := `
+	DELETE FROM unknown_rejections
+	WHERE (webhook_id, reason) IN (
+		SELECT webhook_id, reason FROM unknown_rejections
+		ORDER BY last_rejected_at DESC
+		OFFSET $1
+	)`
+	if _, err := tx.ExecContext(ctx, trim, MaxUnknownRejections); err != nil {
+		return fmt.Errorf("failed to trim unknown rejections: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rejections: %w", err)
+	}
+	return nil
+}
+
+// GetUnknownRejections retrieves the rejection counters of a webhook ID
+// that does not exist and never did, most recent first.
+func (db *DB) GetUnknownRejections(ctx context.Context, webhookID string) ([]Rejection, error) {
+	query := `
+	SELECT webhook_id, reason, count, last_rejected_at
+	FROM unknown_rejections
+	WHERE webhook_id = $1
+		AND NOT EXISTS (SELECT 1 FROM webhooks WHERE id = $1)
+		AND NOT EXISTS (SELECT 1 FROM deleted_webhooks WHERE id = $1)
+	ORDER BY last_rejected_at DESC`
+
+	rows, err := db.QueryContext(ctx, query, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unknown rejections for %s: %w", webhookID, err)
+	}
+	defer rows.Close()
+
+	rejections := []Rejection{}
+	for rows.Next() {
+		var rj Rejection
+		if err := rows.Scan(&rj.WebhookID, &rj.Reason, &rj.Count, &rj.LastRejectedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rejection row: %w", err)
+		}
+		rejections = append(rejections, rj)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return rejections, nil
+}
+
+// GetRejectionsForUser retrieves the rejection counters of a user's current
+// and deleted webhooks, most recent first.
+func (db *DB) GetRejectionsForUser(ctx context.Context, userID string) ([]Rejection, error) {
+	query := `
+	SELECT rj.webhook_id, rj.reason, rj.count, rj.last_rejected_at
+	FROM rejections rj
+	WHERE rj.webhook_id IN (
+		SELECT id FROM webhooks WHERE user_id = $1
+		UNION
+		SELECT id FROM deleted_webhooks WHERE user_id = $1
+	)
+	ORDER BY rj.last_rejected_at DESC`
+
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rejections for user %s: %w", userID, err)
+	}
+	defer rows.Close()
+
+	rejections := []Rejection{}
+	for rows.Next() {
+		var rj Rejection
+		if err := rows.Scan(&rj.WebhookID, &rj.Reason, &rj.Count, &rj.LastRejectedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rejection row: %w", err)
+		}
+		rejections = append(rejections, rj)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return rejections, nil
+}
+
+// GetExpiredWebhooks returns the IDs of up to limit webhooks whose expiry
+// has passed, the longest expired first.
+func (db *DB) GetExpiredWebhooks(ctx context.Context, limit int) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id FROM webhooks WHERE expires_at <= NOW() ORDER BY expires_at LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+
+	return ids, nil
+}
+
+// DrainExpiredWebhook deletes up to limit requests of a webhook whose expiry
+// has passed, pinned ones included, so that deleting the webhook does not
+// cascade to all of them in one transaction. It returns how many were
+// deleted, none once the expiry has been extended.
+func (db *DB) DrainExpiredWebhook(ctx context.Context, webhookID string, limit int) (int, error) {
+	query := `
+	DELETE FROM requests
+	WHERE request_id IN (
+		SELECT r.request_id FROM requests r
+		WHERE r.webhook_id = $1
+			AND EXISTS (SELECT 1 FROM webhooks WHERE id = $1 AND expires_at <= NOW())
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)`
+	result, err := db.ExecContext(ctx, query, webhookID, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to drain requests of webhook %s: %w", webhookID, err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(deleted), nil
+}
+
+// DeleteExpiredWebhook deletes a webhook whose expiry has passed and records
+// it as deleted. Drain it first; requests captured since are deleted along
+// with it. It reports false if the webhook is gone or no longer expired.
+func (db *DB) DeleteExpiredWebhook(ctx context.Context, webhookID string) (bool, error) {
+	query := `
+	WITH expired AS (
+		DELETE FROM webhooks
+		WHERE id = $1 AND expires_at <= NOW()
+		RETURNING id, user_id
+	)
+	INSERT INTO deleted_webhooks (id, user_id, expired)
+	SELECT id, user_id, TRUE FROM expired
+	ON CONFLICT (id) DO UPDATE SET user_id = EXCLUDED.user_id, deleted_at = NOW(), expired = TRUE`
+	result, err := db.ExecContext(ctx, query, webhookID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete expired webhook %s: %w", webhookID, err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return deleted > 0, nil
+}
@@ -0,0 +1,111 @@
+// Package dedup extracts the provider delivery or event ID that identifies
+// retries of the same webhook.
+package dedup
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"hookinator/internal/database"
+	"hookinator/internal/jsonpath"
+	"hookinator/internal/sourcetype"
+	"hookinator/internal/utils"
+)
+
+const (
+	// DefaultWindow is how long a key is remembered when the webhook does
+	// not configure its own window.
+	DefaultWindow = 24 * time.Hour
+	// MaxWindow bounds the configurable window.
+	MaxWindow = 30 * 24 * time.Hour
+	// maxKeyLength bounds what is stored and indexed per request.
+	maxKeyLength = 512
+)
+
+// builtinHeaders carry a per-delivery ID that providers repeat on retries,
+// in the order they are tried.
+var builtinHeaders = []string{
+	"X-GitHub-Delivery",
+	"Webhook-Id",
+	"X-Shopify-Webhook-Id",
+	"Idempotency-Key",
+}
+
+// builtinPaths locate the event ID in the body of providers that do not
+// send an ID header, by source type.
+var builtinPaths = map[string]string{
+	"stripe": "$.id",
+	"slack":  "$.event_id",
+}
+
+// Rule extracts deduplication keys for one webhook.
+type Rule struct {
+	header     string
+	path       *jsonpath.Path
+	sourcePath *jsonpath.Path
+	window     time.Duration
+}
+
+// Compile validates a dedup config. A config without a header or JSON path
+// uses the built-in provider IDs.
+func Compile(cfg database.DedupConfig, sourceType string) (*Rule, error) {
+	if cfg.Header != "" && cfg.JSONPath != "" {
+		return nil, fmt.Errorf("set either header or json_path, not both")
+	}
+	window := time.Duration(cfg.WindowSeconds) * time.Second
+	if window < 0 || window > MaxWindow {
+		return nil, fmt.Errorf("window_seconds must be between 1 and %d", int(MaxWindow.Seconds()))
+	}
+	if window == 0 {
+		window = DefaultWindow
+	}
+
+	rule := &Rule{header: http.CanonicalHeaderKey(cfg.Header), window: window}
+	if cfg.JSONPath != "" {
+		p, err := jsonpath.Parse(cfg.JSONPath)
+		if err != nil {
+			return nil, err
+		}
+		rule.path = &p
+	}
+	if raw, ok := builtinPaths[sourcetype.Normalize(sourceType)]; ok {
+		p, err := jsonpath.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		rule.sourcePath = &p
+	}
+	return rule, nil
+}
+
+// Window is how long a key identifies duplicates.
+func (r *Rule) Window() time.Duration {
+	return r.window
+}
+
+// Key returns the deduplication key of a request, or "" if it has none.
+// doc is the body decoded with encoding/json, nil if it is not JSON.
+func (r *Rule) Key(header http.Header, doc interface{}) string {
+	return utils.StorableText(r.key(header, doc), maxKeyLength)
+}
+
+func (r *Rule) key(header http.Header, doc interface{}) string {
+	switch {
+	case r.header != "":
+		return header.Get(r.header)
+	case r.path != nil:
+		key, _ := r.path.LookupString(doc)
+		return key
+	}
+	for _, name := range builtinHeaders {
+		if key := header.Get(name); key != "" {
+			return key
+		}
+	}
+	if r.sourcePath != nil {
+		key, _ := r.sourcePath.LookupString(doc)
+		return key
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package dedup
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"hookinator/internal/database"
+)
+
+func TestKey(t *testing.T) {
+	stripeBody := `{"id": "evt_123", "type": "invoice.paid", "data": {"object": {"id": "in_1"}}}`
+	tests := []struct {
+		name       string
+		cfg        database.DedupConfig
+		sourceType string
+		header     http.Header
+		body       string
+		want       string
+	}{
+		{"GitHub delivery", database.DedupConfig{}, "github", http.Header{"X-Github-Delivery": {"d-1"}}, `{}`, "d-1"},
+		{"Standard Webhooks ID", database.DedupConfig{}, "", http.Header{"Webhook-Id": {"msg_1"}}, "", "msg_1"},
+		{"Shopify webhook ID", database.DedupConfig{}, "shopify", http.Header{"X-Shopify-Webhook-Id": {"s-1"}}, "", "s-1"},
+		{"idempotency key", database.DedupConfig{}, "", http.Header{"Idempotency-Key": {"k-1"}}, "", "k-1"},
+		{
+			name:   "built-in headers in order",
+			header: http.Header{"Idempotency-Key": {"k-1"}, "Webhook-Id": {"msg_1"}, "X-Github-Delivery": {"d-1"}},
+			want:   "d-1",
+		},
+		{"Stripe event ID", database.DedupConfig{}, "stripe", nil, stripeBody, "evt_123"},
+		{"Stripe source type in any case", database.DedupConfig{}, " Stripe ", nil, stripeBody, "evt_123"},
+		{"header before Stripe body", database.DedupConfig{}, "stripe", http.Header{"Idempotency-Key": {"k-1"}}, stripeBody, "k-1"},
+		{"Stripe body that is not JSON", database.DedupConfig{}, "stripe", nil, "id=evt_123", ""},
+		{"Slack event ID", database.DedupConfig{}, "slack", nil, `{"event_id": "Ev1", "type": "event_callback"}`, "Ev1"},
+		{"no body path for generic", database.DedupConfig{}, "generic", nil, stripeBody, ""},
+		{"nothing to go on", database.DedupConfig{}, "", nil, "", ""},
+		{
+			name:       "custom header over built-ins",
+			cfg:        database.DedupConfig{Header: "x-request-id"},
+			sourceType: "stripe",
+			header:     http.Header{"X-Request-Id": {"r-1"}, "X-Github-Delivery": {"d-1"}},
+			body:       stripeBody,
+			want:       "r-1",
+		},
+		{
+			name:       "custom header missing",
+			cfg:        database.DedupConfig{Header: "X-Request-Id"},
+			sourceType: "stripe",
+			header:     http.Header{"X-Github-Delivery": {"d-1"}},
+			body:       stripeBody,
+			want:       "",
+		},
+		{
+			name:       "custom path over built-ins",
+			cfg:        database.DedupConfig{JSONPath: "$.data.object.id"},
+			sourceType: "stripe",
+			header:     http.Header{"Webhook-Id": {"msg_1"}},
+			body:       stripeBody,
+			want:       "in_1",
+		},
+		{"NUL bytes removed", database.DedupConfig{}, "", http.Header{"Webhook-Id": {"a\x00b"}}, "", "ab"},
+		{"invalid UTF-8 removed", database.DedupConfig{}, "", http.Header{"Webhook-Id": {"a\xffb"}}, "", "ab"},
+		{"long key truncated", database.DedupConfig{}, "", http.Header{"Webhook-Id": {strings.Repeat("k", 600)}}, "", strings.Repeat("k", maxKeyLength)},
+	}
+	for _, tt := range tests {
+		rule, err := Compile(tt.cfg, tt.sourceType)
+		if err != nil {
+			t.Errorf("%s: Compile: %v", tt.name, err)
+			continue
+		}
+		var doc interface{}
+		if err := json.Unmarshal([]byte(tt.body), &doc); err != nil {
+			doc = nil
+		}
+		header := tt.header
+		if header == nil {
+			header = http.Header{}
+		}
+		if got := rule.Key(header, doc); got != tt.want {
+			t.Errorf("%s: Key = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        database.DedupConfig
+		wantWindow time.Duration
+		wantErr    bool
+	}{
+		{name: "default window", cfg: database.DedupConfig{}, wantWindow: DefaultWindow},
+		{name: "custom window", cfg: database.DedupConfig{WindowSeconds: 60}, wantWindow: time.Minute},
+		{name: "longest window", cfg: database.DedupConfig{WindowSeconds: int(MaxWindow.Seconds())}, wantWindow: MaxWindow},
+		{name: "window too long", cfg: database.DedupConfig{WindowSeconds: int(MaxWindow.Seconds()) + 1}, wantErr: true},
+		{name: "negative window", cfg: database.DedupConfig{WindowSeconds: -1}, wantErr: true},
+		{name: "header and path", cfg: database.DedupConfig{Header: "X-Id", JSONPath: "$.id"}, wantErr: true},
+		{name: "bad path", cfg: database.DedupConfig{JSONPath: "a..b"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		rule, err := Compile(tt.cfg, "")
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: Compile succeeded, want error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Compile: %v", tt.name, err)
+			continue
+		}
+		if rule.Window() != tt.wantWindow {
+			t.Errorf("%s: Window = %v, want %v", tt.name, rule.Window(), tt.wantWindow)
+		}
+	}
+}
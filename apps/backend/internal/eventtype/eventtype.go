@@ -0,0 +1,88 @@
+// Package eventtype extracts the provider event type, such as "push" or
+// "invoice.paid", that a request carries.
+package eventtype
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"hookinator/internal/database"
+	"hookinator/internal/jsonpath"
+	"hookinator/internal/sourcetype"
+	"hookinator/internal/utils"
+)
+
+// maxLength bounds what is stored and indexed per request.
+const maxLength = 255
+
+// builtin says where each source type puts its event type: a header, or
+// else JSON paths tried in order.
+var builtin = map[string]struct {
+	header string
+	paths  []string
+}{
+	"github":            {header: "X-GitHub-Event"},
+	"shopify":           {header: "X-Shopify-Topic"},
+	"stripe":            {paths: []string{"$.type"}},
+	"slack":             {paths: []string{"$.event.type", "$.type"}},
+	"standard-webhooks": {paths: []string{"$.type"}},
+}
+
+// Rule extracts event types for one webhook.
+type Rule struct {
+	header string
+	paths  []jsonpath.Path
+}
+
+// Compile builds the rule of a webhook. A custom config takes precedence
+// over the built-in location for the source type; with neither, Compile
+// returns nil.
+func Compile(cfg *database.EventTypeConfig, sourceType string) (*Rule, error) {
+	if cfg != nil {
+		switch {
+		case cfg.Header != "" && cfg.JSONPath != "":
+			return nil, fmt.Errorf("set either header or json_path, not both")
+		case cfg.Header != "":
+			return &Rule{header: http.CanonicalHeaderKey(cfg.Header)}, nil
+		case cfg.JSONPath != "":
+			p, err := jsonpath.Parse(cfg.JSONPath)
+			if err != nil {
+				return nil, err
+			}
+			return &Rule{paths: []jsonpath.Path{p}}, nil
+		default:
+			return nil, fmt.Errorf("header or json_path is required")
+		}
+	}
+
+	b, ok := builtin[sourcetype.Normalize(sourceType)]
+	if !ok {
+		return nil, nil
+	}
+	rule := &Rule{header: b.header}
+	for _, raw := range b.paths {
+		p, err := jsonpath.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		rule.paths = append(rule.paths, p)
+	}
+	return rule, nil
+}
+
+// Extract returns the event type of a request, or "" if it has none. doc is
+// the body decoded with encoding/json, nil if it is not JSON.
+func (r *Rule) Extract(header http.Header, doc interface{}) string {
+	var eventType string
+	if r.header != "" {
+		eventType = header.Get(r.header)
+	}
+	for _, p := range r.paths {
+		if eventType != "" {
+			break
+		}
+		eventType, _ = p.LookupString(doc)
+	}
+	return strings.TrimSpace(utils.StorableText(eventType, maxLength))
+}
@@ -0,0 +1,85 @@
+package eventtype
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"hookinator/internal/database"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        *database.EventTypeConfig
+		sourceType string
+		header     http.Header
+		body       string
+		want       string
+	}{
+		{"GitHub", nil, "github", http.Header{"X-Github-Event": {"push"}}, `{"type": "ignored"}`, "push"},
+		{"GitHub without header", nil, "GitHub", nil, `{"type": "ignored"}`, ""},
+		{"Shopify", nil, "shopify", http.Header{"X-Shopify-Topic": {"orders/create"}}, `{}`, "orders/create"},
+		{"Stripe", nil, "stripe", nil, `{"id": "evt_1", "type": "invoice.paid"}`, "invoice.paid"},
+		{"Stripe body that is not JSON", nil, "stripe", nil, "type=invoice.paid", ""},
+		{"Slack event callback", nil, "slack", nil, `{"type": "event_callback", "event": {"type": "app_mention"}}`, "app_mention"},
+		{"Slack URL verification", nil, "slack", nil, `{"type": "url_verification"}`, "url_verification"},
+		{"Standard Webhooks", nil, "standard-webhooks", nil, `{"type": "user.created"}`, "user.created"},
+		{"non-string type", nil, "stripe", nil, `{"type": 42}`, "42"},
+		{"null type", nil, "stripe", nil, `{"type": null}`, ""},
+		{
+			name:       "custom header over built-in",
+			cfg:        &database.EventTypeConfig{Header: "x-event-name"},
+			sourceType: "stripe",
+			header:     http.Header{"X-Event-Name": {"custom"}},
+			body:       `{"type": "invoice.paid"}`,
+			want:       "custom",
+		},
+		{
+			name:       "custom path over built-in",
+			cfg:        &database.EventTypeConfig{JSONPath: "$.meta.kind"},
+			sourceType: "github",
+			header:     http.Header{"X-Github-Event": {"push"}},
+			body:       `{"meta": {"kind": "deploy"}}`,
+			want:       "deploy",
+		},
+		{"whitespace trimmed", nil, "github", http.Header{"X-Github-Event": {"  push "}}, "", "push"},
+		{"NUL bytes removed", nil, "github", http.Header{"X-Github-Event": {"pu\x00sh"}}, "", "push"},
+		{"invalid UTF-8 removed", nil, "github", http.Header{"X-Github-Event": {"pu\xffsh"}}, "", "push"},
+		{"long type truncated", nil, "github", http.Header{"X-Github-Event": {strings.Repeat("e", 300)}}, "", strings.Repeat("e", maxLength)},
+	}
+	for _, tt := range tests {
+		rule, err := Compile(tt.cfg, tt.sourceType)
+		if err != nil || rule == nil {
+			t.Errorf("%s: Compile = %v, %v", tt.name, rule, err)
+			continue
+		}
+		var doc interface{}
+		if err := json.Unmarshal([]byte(tt.body), &doc); err != nil {
+			doc = nil
+		}
+		header := tt.header
+		if header == nil {
+			header = http.Header{}
+		}
+		if got := rule.Extract(header, doc); got != tt.want {
+			t.Errorf("%s: Extract = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	if rule, err := Compile(nil, "generic"); rule != nil || err != nil {
+		t.Errorf("Compile(nil, generic) = %v, %v; want no rule", rule, err)
+	}
+	for _, cfg := range []database.EventTypeConfig{
+		{},
+		{Header: "X-Event", JSONPath: "$.type"},
+		{JSONPath: "$."},
+	} {
+		if _, err := Compile(&cfg, "stripe"); err == nil {
+			t.Errorf("Compile(%+v) succeeded, want error", cfg)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package forwarder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"hookinator/internal/database"
+	"hookinator/internal/outbound"
+	"hookinator/internal/secrets"
+)
+
+const (
+	// lease is how long a worker owns a claimed job before another worker
+	// may pick it up again. It must comfortably exceed the client timeout.
+	lease = time.Minute
+	// maxRetryAfter caps how far a destination can push back a retry.
+	maxRetryAfter = 24 * time.Hour
+	// maxResponseBody is how much of a destination's response is recorded.
+	maxResponseBody = 64 << 10
+	// defaultTimeout applies to the forward URL, which has no per-destination
+	// setting.
+	defaultTimeout = 10 * time.Second
+)
+
+// Config controls the delivery workers.
+type Config struct {
+	Workers      int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	// AllowedNetworks are internal ranges that deliveries may reach anyway;
+	// all other loopback, private and link-local addresses are refused.
+	AllowedNetworks []netip.Prefix
+}
+
+// Forwarder delivers queued webhook requests to their forward URL and
+// destinations. Every destination has its own job, so one failing endpoint
+// never holds up the others.
+type Forwarder struct {
+	DB     *database.DB
+	Client *http.Client
+	Config Config
+	// Outbound decides which addresses Client may connect to.
+	Outbound *outbound.Policy
+	// Secrets decrypts the signing secrets of webhooks; without it requests
+	// are forwarded unsigned.
+	Secrets *secrets.Box
+
+	wake chan struct{}
+
+	// background outlives requests that start bulk replays; it is cancelled
+	// when Run returns.
+	background       context.Context
+	cancelBackground context.CancelFunc
+	replays          sync.WaitGroup
+}
+
+// New creates a new Forwarder with dependencies.
+func New(db *database.DB, secretBox *secrets.Box, cfg Config) *Forwarder {
+	background, cancel := context.WithCancel(context.Background())
+	policy := outbound.NewPolicy(cfg.AllowedNetworks)
+	return &Forwarder{
+		DB:               db,
+		Client:           policy.Client(),
+		Config:           cfg,
+		Outbound:         policy,
+		Secrets:          secretBox,
+		wake:             make(chan struct{}, 1),
+		background:       background,
+		cancelBackground: cancel,
+	}
+}
+
+// Notify wakes an idle worker so a freshly queued job is delivered without
+// waiting for the next poll.
+func (f *Forwarder) Notify() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run starts the workers and blocks until ctx is cancelled, every worker has
+// finished its current job and running bulk replays have stopped.
+func (f *Forwarder) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < f.Config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.work(ctx)
+		}()
+	}
+	log.Printf("Delivery queue started with %d workers", f.Config.Workers)
+	wg.Wait()
+	f.cancelBackground()
+	f.replays.Wait()
+	log.Println("Delivery queue stopped.")
+}
+
+func (f *Forwarder) work(ctx context.Context) {
+	ticker := time.NewTicker(f.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		jobs, err := f.DB.ClaimDeliveryJobs(ctx, 1, lease)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to claim delivery jobs: %v", err)
+		}
+		for _, job := range jobs {
+			f.deliver(ctx, job)
+		}
+		if len(jobs) > 0 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *Forwarder) deliver(ctx context.Context, job database.DeliveryJob) {
+	// Use a fresh context so the outcome is recorded even during shutdown.
+	dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if job.Disabled {
+		if err := f.DB.FailDeliveryJob(dbCtx, job.ID, "destination disabled"); err != nil {
+			log.Printf("%v", err)
+		}
+		return
+	}
+
+	attempt, retryAfter, err := f.send(ctx, job)
+
+	if err != nil && ctx.Err() != nil {
+		// Interrupted by shutdown: hand the job back untouched.
+		if err := f.DB.ReleaseDeliveryJob(dbCtx, job.ID); err != nil {
+			log.Printf("%v", err)
+		}
+		return
+	}
+
+	if err := f.DB.SaveDelivery(dbCtx, job.WebhookID, attempt); err != nil {
+		log.Printf("%v", err)
+	}
+
+	if err == nil {
+		if err := f.DB.CompleteDeliveryJob(dbCtx, job.ID); err != nil {
+			log.Printf("%v", err)
+		}
+		return
+	}
+
+	if job.Attempts >= f.Config.MaxAttempts {
+		log.Printf("Giving up on request %d for %s after %d attempts: %v", job.RequestID, job.WebhookID, job.Attempts, err)
+		if err := f.DB.FailDeliveryJob(dbCtx, job.ID, err.Error()); err != nil {
+			log.Printf("%v", err)
+		}
+		return
+	}
+
+	delay := f.backoff(job.Attempts)
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	log.Printf("Delivery of request %d for %s failed (attempt %d/%d), retrying in %s: %v", job.RequestID, job.WebhookID, job.Attempts, f.Config.MaxAttempts, delay, err)
+	if err := f.DB.RetryDeliveryJob(dbCtx, job.ID, time.Now().Add(delay), err.Error()); err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+// send performs a single delivery attempt and describes it as a Delivery. A
+// non-2xx response is an error; the returned duration is the destination's
+// Retry-After, if any.
+func (f *Forwarder) send(ctx context.Context, job database.DeliveryJob) (database.Delivery, time.Duration, error) {
+	attempt := database.Delivery{
+		RequestID:     job.RequestID,
+		DestinationID: job.DestinationID,
+		TargetURL:     requestURL(job.TargetURL, job.Path, job.Query),
+		Attempt:       job.Attempts,
+	}
+	if job.ID != 0 {
+		attempt.JobID = &job.ID
+	}
+
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, job.Method, attempt.TargetURL, bytes.NewReader([]byte(job.Body)))
+	if err != nil {
+		err = fmt.Errorf("failed to create forward request: %w", err)
+		attempt.Error = err.Error()
+		return attempt, 0, err
+	}
+	req.Header = job.Headers.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for name, value := range job.ExtraHeaders {
+		req.Header.Set(name, value)
+	}
+	if err := f.sign(ctx, job, req); err != nil {
+		attempt.Error = err.Error()
+		return attempt, 0, err
+	}
+
+	start := time.Now()
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		attempt.DurationMS = time.Since(start).Milliseconds()
+		attempt.Error = err.Error()
+		return attempt, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	attempt.DurationMS = time.Since(start).Milliseconds()
+	attempt.StatusCode = resp.StatusCode
+	attempt.ResponseHeaders = resp.Header
+	// Postgres TEXT must be valid UTF-8 without NUL bytes.
+	attempt.ResponseBody = strings.ReplaceAll(strings.ToValidUTF8(string(body), "\uFFFD"), "\x00", "")
+	if readErr != nil {
+		attempt.Error = fmt.Sprintf("failed to read response body: %v", readErr)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("destination responded with status %d", resp.StatusCode)
+		attempt.Error = err.Error()
+		return attempt, parseRetryAfter(resp.Header.Get("Retry-After")), err
+	}
+
+	log.Printf("Webhook for %s forwarded to %s, status: %d", job.WebhookID, attempt.TargetURL, resp.StatusCode)
+	return attempt, 0, nil
+}
+
+// requestURL appends the sub-path and query string a request was captured
+// with to the target URL, so senders that encode routing information in the
+// URL keep working behind the forwarder.
+func requestURL(target, path, query string) string {
+	if path == "" && query == "" {
+		return target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	if path != "" {
+		u.Path = strings.TrimSuffix(u.Path, "/") + path
+		u.RawPath = ""
+	}
+	if query != "" {
+		if u.RawQuery != "" {
+			u.RawQuery += "&" + query
+		} else {
+			u.RawQuery = query
+		}
+	}
+	return u.String()
+}
+
+// backoff returns the delay before the next attempt: exponential in the
+// number of attempts so far, capped at MaxBackoff, with equal jitter so
+// retries from a burst of failures spread out.
+func (f *Forwarder) backoff(attempts int) time.Duration {
+	max := f.Config.MaxBackoff
+	d := f.Config.BaseBackoff
+	// Doubling stops at the cap, so a large base cannot overflow and wrap
+	// around to a short delay.
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+		if d <= 0 || d > max {
+			d = max
+		}
+	}
+	if d <= 0 || d > max {
+		d = max
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter understands both forms of the Retry-After header: a number
+// of seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		d = time.Until(t)
+	}
+	if d < 0 {
+		return 0
+	}
+	if d > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return d
+}
@@ -0,0 +1,37 @@
+package forwarder
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		max      time.Duration
+		attempts int
+		want     time.Duration
+	}{
+		{"first attempt", 5 * time.Second, time.Hour, 1, 5 * time.Second},
+		{"doubles", 5 * time.Second, time.Hour, 4, 40 * time.Second},
+		{"capped", 5 * time.Second, time.Hour, 20, time.Hour},
+		{"many attempts", 5 * time.Second, time.Hour, 1000, time.Hour},
+		{"base above cap", 2 * time.Hour, time.Hour, 1, time.Hour},
+		{"large base does not wrap", time.Duration(math.MaxInt64 / 3), time.Duration(math.MaxInt64), 40, time.Duration(math.MaxInt64)},
+		{"huge base", 1000 * time.Hour, 2000 * time.Hour, 64, 2000 * time.Hour},
+		{"shift past 64 bits", 1<<40 + 1, 24 * time.Hour, 32, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		f := &Forwarder{Config: Config{BaseBackoff: tt.base, MaxBackoff: tt.max}}
+		for i := 0; i < 20; i++ {
+			// Equal jitter gives between half and all of the delay.
+			got := f.backoff(tt.attempts)
+			if got < tt.want/2 || got > tt.want {
+				t.Errorf("%s: backoff(%d) = %v, want between %v and %v", tt.name, tt.attempts, got, tt.want/2, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"hookinator/internal/database"
+	"hookinator/internal/utils"
+)
+
+const (
+	// maxReplayGap caps each wait of a bulk replay that preserves timing.
+	maxReplayGap = time.Minute
+	// maxReplayDuration bounds how long a bulk replay may run.
+	maxReplayDuration = time.Hour
+	// replayPollInterval is how often a waiting bulk replay checks whether
+	// it was cancelled.
+	replayPollInterval = 5 * time.Second
+)
+
+var (
+	// ErrNoTargets is returned when a replay has nowhere to send to.
+	ErrNoTargets = errors.New("webhook has no forward URL or enabled destinations")
+	// ErrReplayTooLong is returned when the waits of a bulk replay that
+	// preserves timing would add up to more than maxReplayDuration.
+	ErrReplayTooLong = fmt.Errorf("preserving timing would take longer than %v", maxReplayDuration)
+)
+
+// ReplayOptions overrides parts of a stored request when it is replayed.
+// Zero values keep what was captured.
+type ReplayOptions struct {
+	// URL replaces the webhook's forward URL and destinations.
+	URL     string
+	Method  string
+	Headers http.Header
+	Body    *string
+}
+
+// Replay re-sends a stored request once to every target, bypassing the queue,
+// and records each attempt as a replay delivery.
+func (f *Forwarder) Replay(ctx context.Context, webhookID string, req database.WebhookRequest, opts ReplayOptions) ([]database.Delivery, error) {
+	targets, err := f.replayTargets(ctx, webhookID, opts)
+	if err != nil {
+		return nil, err
+	}
+	return f.replay(ctx, webhookID, req, targets, opts), nil
+}
+
+// ReplayBatch re-sends stored requests one after another in the background,
+// in the order given. With preserveTiming it waits between requests as long
+// as originally passed between their arrivals, up to maxReplayGap each. It
+// returns once the targets are resolved and the replay is recorded; results
+// are recorded as replay deliveries. The replay stops early when it is
+// cancelled through the database, after maxReplayDuration, or on shutdown.
+func (f *Forwarder) ReplayBatch(ctx context.Context, webhookID string, reqs []database.WebhookRequest, opts ReplayOptions, preserveTiming bool) (database.BulkReplay, error) {
+	waits, err := replayWaits(reqs, preserveTiming)
+	if err != nil {
+		return database.BulkReplay{}, err
+	}
+	targets, err := f.replayTargets(ctx, webhookID, opts)
+	if err != nil {
+		return database.BulkReplay{}, err
+	}
+	id, err := utils.NewULID(time.Now())
+	if err != nil {
+		return database.BulkReplay{}, err
+	}
+	replay, err := f.DB.CreateBulkReplay(ctx, id, webhookID, len(reqs))
+	if err != nil {
+		return database.BulkReplay{}, err
+	}
+
+	f.replays.Add(1)
+	go func() {
+		defer f.replays.Done()
+		ctx, cancel := context.WithTimeout(f.background, maxReplayDuration)
+		defer cancel()
+
+		sent := 0
+		status := database.ReplayFinished
+		for i, req := range reqs {
+			if s := f.pauseReplay(ctx, id, sent, waits[i]); s != database.ReplayRunning {
+				status = s
+				break
+			}
+			f.replay(ctx, webhookID, req, targets, opts)
+			sent++
+		}
+
+		dbCtx, cancelDB := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelDB()
+		if _, err := f.DB.UpdateBulkReplay(dbCtx, id, sent, status); err != nil {
+			log.Printf("%v", err)
+		}
+		log.Printf("Bulk replay %s for %s %s: %d of %d requests", id, webhookID, status, sent, len(reqs))
+	}()
+	return replay, nil
+}
+
+// replayWaits returns how long a bulk replay waits before each request:
+// nothing unless preserveTiming is set, otherwise the original gap capped at
+// maxReplayGap. It returns ErrReplayTooLong if the waits alone would exceed
+// maxReplayDuration.
+func replayWaits(reqs []database.WebhookRequest, preserveTiming bool) ([]time.Duration, error) {
+	waits := make([]time.Duration, len(reqs))
+	if !preserveTiming {
+		return waits, nil
+	}
+	var total time.Duration
+	for i := 1; i < len(reqs); i++ {
+		gap := reqs[i].Timestamp.Sub(reqs[i-1].Timestamp)
+		if gap < 0 {
+			gap = 0
+		}
+		if gap > maxReplayGap {
+			gap = maxReplayGap
+		}
+		waits[i] = gap
+		total += gap
+	}
+	if total > maxReplayDuration {
+		return nil, ErrReplayTooLong
+	}
+	return waits, nil
+}
+
+// pauseReplay records that a bulk replay has sent sent requests and waits
+// for d, checking every replayPollInterval whether the replay was cancelled.
+// It returns ReplayRunning if the replay should go on, or the status it
+// ended with.
+func (f *Forwarder) pauseReplay(ctx context.Context, id string, sent int, d time.Duration) string {
+	for {
+		if err := ctx.Err(); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return database.ReplayTimedOut
+			}
+			return database.ReplayInterrupted
+		}
+		status, err := f.DB.UpdateBulkReplay(ctx, id, sent, database.ReplayRunning)
+		if err != nil {
+			log.Printf("%v", err)
+		} else if status != database.ReplayRunning {
+			return status
+		}
+		if d <= 0 {
+			return database.ReplayRunning
+		}
+
+		step := d
+		if step > replayPollInterval {
+			step = replayPollInterval
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(step):
+		}
+		d -= step
+	}
+}
+
+func (f *Forwarder) replayTargets(ctx context.Context, webhookID string, opts ReplayOptions) ([]database.DeliveryJob, error) {
+	if opts.URL != "" {
+		return []database.DeliveryJob{{WebhookID: webhookID, TargetURL: opts.URL}}, nil
+	}
+	targets, err := f.DB.GetDeliveryTargets(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, ErrNoTargets
+	}
+	return targets, nil
+}
+
+func (f *Forwarder) replay(ctx context.Context, webhookID string, req database.WebhookRequest, targets []database.DeliveryJob, opts ReplayOptions) []database.Delivery {
+	deliveries := make([]database.Delivery, 0, len(targets))
+	for _, target := range targets {
+		job := target
+		job.RequestID = req.ID
+		job.Attempts = 1
+		job.Method = req.Method
+		job.Path = req.Path
+		job.Query = req.Query
+		job.Headers = req.Headers
+		job.Body = req.Body
+		if opts.Method != "" {
+			job.Method = opts.Method
+		}
+		if opts.Headers != nil {
+			job.Headers = opts.Headers
+		}
+		if opts.Body != nil {
+			job.Body = *opts.Body
+		}
+
+		attempt, _, err := f.send(ctx, job)
+		attempt.Replay = true
+		if err != nil && ctx.Err() != nil {
+			// The caller went away; there is nothing meaningful to record.
+			return deliveries
+		}
+
+		dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := f.DB.SaveDelivery(dbCtx, webhookID, attempt); err != nil {
+			log.Printf("%v", err)
+		}
+		cancel()
+		attempt.CreatedAt = time.Now()
+		deliveries = append(deliveries, attempt)
+	}
+	return deliveries
+}
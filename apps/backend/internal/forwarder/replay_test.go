@@ -0,0 +1,51 @@
+package forwarder
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"hookinator/internal/database"
+)
+
+func TestReplayWaits(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(offsets ...time.Duration) []database.WebhookRequest {
+		reqs := make([]database.WebhookRequest, len(offsets))
+		for i, offset := range offsets {
+			reqs[i].Timestamp = base.Add(offset)
+		}
+		return reqs
+	}
+	hourly := make([]time.Duration, 62)
+	for i := range hourly {
+		hourly[i] = time.Duration(i) * time.Hour
+	}
+
+	tests := []struct {
+		name           string
+		reqs           []database.WebhookRequest
+		preserveTiming bool
+		want           []time.Duration
+		wantErr        error
+	}{
+		{"no timing", at(0, time.Hour, 2*time.Hour), false, []time.Duration{0, 0, 0}, nil},
+		{"original gaps", at(0, time.Second, 3*time.Second), true, []time.Duration{0, time.Second, 2 * time.Second}, nil},
+		{"gap capped", at(0, 10*time.Minute, 10*time.Minute+time.Second), true, []time.Duration{0, maxReplayGap, time.Second}, nil},
+		{"out of order", at(time.Second, 0), true, []time.Duration{0, 0}, nil},
+		{"single request", at(0), true, []time.Duration{0}, nil},
+		{"too long", at(hourly...), true, nil, ErrReplayTooLong},
+		{"too long without timing", at(hourly...), false, make([]time.Duration, len(hourly)), nil},
+	}
+	for _, tt := range tests {
+		got, err := replayWaits(tt.reqs, tt.preserveTiming)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: waits = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
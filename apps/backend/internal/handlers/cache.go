@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"hookinator/internal/database"
+	"hookinator/internal/dedup"
+	"hookinator/internal/eventtype"
+	"hookinator/internal/ipfilter"
+	"hookinator/internal/responder"
+	"hookinator/internal/schema"
+	"hookinator/internal/stubs"
+	"hookinator/internal/verify"
+)
+
+const (
+	// webhookCacheTTL bounds how long another instance's configuration
+	// change can go unnoticed by ingestion.
+	webhookCacheTTL = 10 * time.Second
+	// webhookCacheSize caps memory use when senders probe many random IDs.
+	webhookCacheSize = 10000
+)
+
+// ingestConfig is a webhook together with whatever ingestion derives from
+// its configuration, prepared once per cache fill rather than per request.
+type ingestConfig struct {
+	*database.Webhook
+	// response is nil when the webhook uses the default acknowledgement.
+	response *responder.Template
+	// stubs are the webhook's enabled stubs in matching order.
+	stubs []*stubs.Stub
+	// verifier is nil when signatures are not checked. A request is valid if
+	// it is signed with any of verifySecrets, which hold more than one while
+	// a secret is being rotated.
+	verifier      verify.Verifier
+	verifySecrets []string
+	// dedup is nil when duplicates are not detected.
+	dedup *dedup.Rule
+	// ipFilter is nil when every client address is allowed.
+	ipFilter *ipfilter.Filter
+	// schema is nil when bodies are not validated.
+	schema *schema.Validator
+	// eventType is nil when neither the webhook nor its source type says
+	// where the event type is.
+	eventType *eventtype.Rule
+}
+
+func newIngestConfig(webhook *database.Webhook, defs []database.Stub) *ingestConfig {
+	cfg := &ingestConfig{Webhook: webhook}
+	if webhook.Response != nil {
+		tmpl, err := responder.Compile(*webhook.Response)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid response config of webhook %s: %v", webhook.ID, err)
+		} else {
+			cfg.response = tmpl
+		}
+	}
+	if webhook.Verification != nil {
+		if v, ok := verify.Lookup(webhook.SourceType); ok {
+			cfg.verifier = v
+		} else {
+			log.Printf("Warning: webhook %s verifies signatures but source type %q has no scheme", webhook.ID, webhook.SourceType)
+		}
+	}
+	if webhook.IPFilter != nil {
+		filter, err := ipfilter.Compile(*webhook.IPFilter)
+		if err != nil {
+			// Failing open would silently expose the webhook, so an
+			// unreadable filter blocks everything instead.
+			log.Printf("Warning: invalid IP filter of webhook %s blocks all requests: %v", webhook.ID, err)
+			filter = ipfilter.DenyAll()
+		}
+		cfg.ipFilter = filter
+	}
+	if webhook.Schema != nil {
+		validator, err := schema.Compile(*webhook.Schema)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid schema of webhook %s: %v", webhook.ID, err)
+		} else {
+			cfg.schema = validator
+		}
+	}
+	rule, err := eventtype.Compile(webhook.EventType, webhook.SourceType)
+	if err != nil {
+		log.Printf("Warning: ignoring invalid event type config of webhook %s: %v", webhook.ID, err)
+		rule, _ = eventtype.Compile(nil, webhook.SourceType)
+	}
+	cfg.eventType = rule
+	if webhook.Dedup != nil {
+		rule, err := dedup.Compile(*webhook.Dedup, webhook.SourceType)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid dedup config of webhook %s: %v", webhook.ID, err)
+		} else {
+			cfg.dedup = rule
+		}
+	}
+	for _, def := range defs {
+		if !def.Enabled {
+			continue
+		}
+		stub, err := stubs.Compile(def)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid stub %d of webhook %s: %v", def.ID, webhook.ID, err)
+			continue
+		}
+		cfg.stubs = append(cfg.stubs, stub)
+	}
+	return cfg
+}
+
+type cachedWebhook struct {
+	config  *ingestConfig
+	err     error
+	expires time.Time
+}
+
+// webhookCache remembers ingestion-time webhook lookups, including misses,
+// so every incoming request does not cost a round trip to Postgres.
+type webhookCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedWebhook
+}
+
+func newWebhookCache() *webhookCache {
+	return &webhookCache{entries: make(map[string]cachedWebhook)}
+}
+
+func (c *webhookCache) get(id string) (cachedWebhook, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[id]
+	if !ok || time.Now().After(entry.expires) {
+		return cachedWebhook{}, false
+	}
+	return entry, true
+}
+
+func (c *webhookCache) put(id string, entry cachedWebhook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= webhookCacheSize {
+		now := time.Now()
+		for key, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= webhookCacheSize {
+			c.entries = make(map[string]cachedWebhook)
+		}
+	}
+	entry.expires = time.Now().Add(webhookCacheTTL)
+	c.entries[id] = entry
+}
+
+func (c *webhookCache) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, id)
+}
+
+// lookupWebhook returns the webhook an incoming request is addressed to. It
+// returns sql.ErrNoRows for unknown IDs and database.ErrWebhookGone or
+// database.ErrWebhookExpired for deleted ones; these outcomes are cached
+// alongside hits.
+func (h *Handler) lookupWebhook(ctx context.Context, id string) (*ingestConfig, error) {
+	if entry, ok := h.webhooks.get(id); ok {
+		return entry.config, entry.err
+	}
+
+	webhook, err := h.DB.GetWebhook(ctx, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, database.ErrWebhookGone) && !errors.Is(err, database.ErrWebhookExpired) {
+		return nil, err
+	}
+	var cfg *ingestConfig
+	if webhook != nil {
+		defs, err := h.DB.GetStubs(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		cfg = newIngestConfig(webhook, defs)
+		if cfg.verifier != nil {
+			if cfg.verifySecrets, err = h.verificationSecrets(ctx, id); err != nil {
+				return nil, err
+			}
+		}
+	}
+	h.webhooks.put(id, cachedWebhook{config: cfg, err: err})
+	return cfg, err
+}
+
+// verificationSecrets decrypts the active verification secrets of a webhook.
+// Secrets that cannot be decrypted are skipped, so the requests they sign
+// are recorded as invalid rather than failing ingestion. Without a master
+// key there are none.
+func (h *Handler) verificationSecrets(ctx context.Context, webhookID string) ([]string, error) {
+	if h.Secrets == nil {
+		return nil, nil
+	}
+	active, err := h.DB.GetActiveSecrets(ctx, webhookID, database.SecretVerification)
+	if err != nil {
+		return nil, err
+	}
+	var plaintexts []string
+	for _, s := range active {
+		plaintext, err := h.Secrets.Open(webhookID, s.Ciphertext)
+		if err != nil {
+			log.Printf("Warning: skipping verification secret %d of webhook %s: %v", s.ID, webhookID, err)
+			continue
+		}
+		plaintexts = append(plaintexts, plaintext)
+	}
+	return plaintexts, nil
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"hookinator/internal/database"
+	"hookinator/internal/dedup"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetDedupConfig returns how duplicate deliveries are detected, or null if
+// they are not.
+func (h *Handler) GetDedupConfig(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	cfg, err := h.DB.GetDedupConfig(r.Context(), webhookID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve dedup config")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, cfg)
+}
+
+// UpdateDedupConfig turns on duplicate detection, keyed on a header, a JSON
+// path, or by default the providers' own delivery IDs.
+func (h *Handler) UpdateDedupConfig(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	var cfg database.DedupConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if _, err := dedup.Compile(cfg, ""); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.DB.UpdateDedupConfig(r.Context(), webhookID, userID, &cfg); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update dedup config")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, cfg)
+}
+
+// DisableDedup stops detecting duplicates.
+func (h *Handler) DisableDedup(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	if err := h.DB.UpdateDedupConfig(r.Context(), webhookID, userID, nil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to disable deduplication")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Deduplication disabled"})
+}
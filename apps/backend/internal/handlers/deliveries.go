@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ListDeliveries returns every forwarding attempt made for a captured request.
+func (h *Handler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+
+	requestID, ok := h.requestID(w, r, webhookID)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.DB.GetDeliveries(r.Context(), webhookID, requestID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Request not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to get deliveries")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, deliveries)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"hookinator/internal/database"
+	"hookinator/internal/outbound"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultDestinationTimeoutMS = 10000
+	maxDestinationTimeoutMS     = 30000
+)
+
+type destinationRequest struct {
+	URL       string            `json:"url"`
+	Enabled   *bool             `json:"enabled"`
+	TimeoutMS int               `json:"timeout_ms"`
+	Headers   map[string]string `json:"headers"`
+}
+
+// toDestination validates the request and fills in defaults. The URL must
+// not point at an address the policy refuses.
+func (req destinationRequest) toDestination(ctx context.Context, policy *outbound.Policy, webhookID string) (database.Destination, string) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return database.Destination{}, "url must be an absolute http(s) URL"
+	}
+	if err := policy.CheckURL(ctx, req.URL); err != nil {
+		return database.Destination{}, "url: " + err.Error()
+	}
+	if req.TimeoutMS == 0 {
+		req.TimeoutMS = defaultDestinationTimeoutMS
+	}
+	if req.TimeoutMS < 0 || req.TimeoutMS > maxDestinationTimeoutMS {
+		return database.Destination{}, "timeout_ms must be between 1 and " + strconv.Itoa(maxDestinationTimeoutMS)
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	if req.Headers == nil {
+		req.Headers = map[string]string{}
+	}
+	return database.Destination{
+		WebhookID: webhookID,
+		URL:       req.URL,
+		Enabled:   enabled,
+		TimeoutMS: req.TimeoutMS,
+		Headers:   req.Headers,
+	}, ""
+}
+
+// ownsWebhook verifies that the authenticated user owns the webhook in the
+// URL, writing an error response if not.
+func (h *Handler) ownsWebhook(w http.ResponseWriter, r *http.Request) bool {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	exists, err := h.DB.CheckWebhookOwnership(r.Context(), webhookID, userID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to verify webhook ownership")
+		return false
+	}
+	if !exists {
+		h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) ListDestinations(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	destinations, err := h.DB.GetDestinations(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve destinations")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, destinations)
+}
+
+func (h *Handler) CreateDestination(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	var req destinationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	destination, problem := req.toDestination(r.Context(), h.Forwarder.Outbound, chi.URLParam(r, "id"))
+	if problem != "" {
+		h.respondWithError(w, http.StatusBadRequest, problem)
+		return
+	}
+
+	created, err := h.DB.CreateDestination(r.Context(), destination)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to create destination")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) UpdateDestination(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	destinationID, err := strconv.ParseInt(chi.URLParam(r, "destinationId"), 10, 64)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid destination ID")
+		return
+	}
+
+	var req destinationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	destination, problem := req.toDestination(r.Context(), h.Forwarder.Outbound, chi.URLParam(r, "id"))
+	if problem != "" {
+		h.respondWithError(w, http.StatusBadRequest, problem)
+		return
+	}
+	destination.ID = destinationID
+
+	updated, err := h.DB.UpdateDestination(r.Context(), destination)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Destination not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update destination")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handler) DeleteDestination(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	destinationID, err := strconv.ParseInt(chi.URLParam(r, "destinationId"), 10, 64)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid destination ID")
+		return
+	}
+
+	if err := h.DB.DeleteDestination(r.Context(), chi.URLParam(r, "id"), destinationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Destination not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to delete destination")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Destination deleted successfully"})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"hookinator/internal/database"
+	"hookinator/internal/eventtype"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetEventTypeConfig returns where the webhook's event type is found, or
+// null if it uses the built-in rule of its source type.
+func (h *Handler) GetEventTypeConfig(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	cfg, err := h.DB.GetEventTypeConfig(r.Context(), webhookID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve event type config")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, cfg)
+}
+
+// UpdateEventTypeConfig reads the event type of new requests from a header
+// or a JSON path instead of the built-in rule.
+func (h *Handler) UpdateEventTypeConfig(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	var cfg database.EventTypeConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if _, err := eventtype.Compile(&cfg, ""); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.DB.UpdateEventTypeConfig(r.Context(), webhookID, userID, &cfg); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update event type config")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, cfg)
+}
+
+// RemoveEventTypeConfig goes back to the built-in rule of the source type.
+func (h *Handler) RemoveEventTypeConfig(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	if err := h.DB.UpdateEventTypeConfig(r.Context(), webhookID, userID, nil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to remove event type config")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Event type config removed"})
+}
+
+// ListEventTypes returns how many captured requests the webhook has of each
+// event type.
+func (h *Handler) ListEventTypes(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	counts, err := h.DB.CountEventTypes(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to count event types")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, counts)
+}
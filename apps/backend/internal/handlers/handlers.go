@@ -1,15 +1,19 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"hookinator/internal/database"
+	"hookinator/internal/forwarder"
+	"hookinator/internal/ingest"
+	"hookinator/internal/rejections"
+	"hookinator/internal/secrets"
+	"hookinator/internal/stream"
 	"hookinator/internal/utils"
-	"io"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
 	"strings"
 	"time"
@@ -23,26 +27,60 @@ import (
 // contextKey is a custom type to avoid context key collisions.
 type contextKey string
 
-const userContextKey = contextKey("userID")
+const (
+	userContextKey       = contextKey("userID")
+	ingestSlotContextKey = contextKey("ingestSlot")
+)
 
 // Handler holds dependencies for the application.
 type Handler struct {
 	DB        *database.DB
-	Client    *http.Client
+	Forwarder *forwarder.Forwarder
+	// Ingest saves captured requests in batches; nil saves each one before
+	// acknowledging it.
+	Ingest *ingest.Buffer
+	// Stream pushes captured requests to live subscribers.
+	Stream *stream.Hub
+	// Rejections counts refused ingestion requests per webhook ID.
+	Rejections *rejections.Counter
+	// Secrets encrypts webhook secrets; nil if no master key is configured.
+	Secrets   *secrets.Box
 	BaseURL   string
 	JWTSecret string
+	// TrustedProxies are the proxies whose X-Forwarded-For header is believed
+	// when resolving a sender's IP address.
+	TrustedProxies []netip.Prefix
+	Limits         IngestLimits
+	// AllowedOrigins are the frontend origins, which may open WebSocket
+	// streams.
+	AllowedOrigins []string
+	// Retention is the default retention policy of webhooks.
+	Retention database.RetentionPolicy
+
+	webhooks    *webhookCache
+	ingestSlots chan struct{}
 }
 
 // New creates a new Handler instance with dependencies.
-func New(db *database.DB, baseURL, jwtSecret string) *Handler {
-	return &Handler{
-		DB: db,
-		Client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		BaseURL:   baseURL,
-		JWTSecret: jwtSecret,
-	}
+func New(db *database.DB, fwd *forwarder.Forwarder, buffer *ingest.Buffer, hub *stream.Hub, rejectionCounter *rejections.Counter, secretBox *secrets.Box, baseURL, jwtSecret string, trustedProxies []netip.Prefix, limits IngestLimits, retention database.RetentionPolicy) *Handler {
+	h := &Handler{
+		DB:             db,
+		Forwarder:      fwd,
+		Ingest:         buffer,
+		Stream:         hub,
+		Rejections:     rejectionCounter,
+		Secrets:        secretBox,
+		BaseURL:        baseURL,
+		JWTSecret:      jwtSecret,
+		TrustedProxies: trustedProxies,
+		Limits:         limits,
+		Retention:      retention,
+		webhooks:       newWebhookCache(),
+	}
+	if limits.MaxConcurrent > 0 {
+		h.ingestSlots = make(chan struct{}, limits.MaxConcurrent)
+	}
+	return h
 }
 
 // --- JSON Response Helpers ---
@@ -106,59 +144,18 @@ func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 
 // HandleGoogleLogin would be here (as implemented before).
 
-// HandleWebhook receives, logs, and forwards an incoming webhook.
-func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
-	// This handler remains public and unchanged.
-	id := chi.URLParam(r, "id")
-
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Cannot read request body")
-		return
-	}
-	defer r.Body.Close()
-
-	webhookReq := database.WebhookRequest{
-		Timestamp: time.Now(),
-		Method:    r.Method,
-		Headers:   r.Header,
-		Body:      string(bodyBytes),
-	}
-
-	if err := h.DB.SaveRequest(r.Context(), id, webhookReq); err != nil {
-		log.Printf("Failed to save webhook request: %v", err)
-	}
-
-	forwardURL, err := h.DB.GetForwardURL(r.Context(), id)
-	if err != nil {
-		log.Printf("Failed to get forward URL for %s: %v", id, err)
-	} else if forwardURL != "" {
-		go func() {
-			req, err := http.NewRequestWithContext(context.Background(), r.Method, forwardURL, bytes.NewReader(bodyBytes))
-			if err != nil {
-				log.Printf("Failed to create forward request for %s: %v", id, err)
-				return
-			}
-			req.Header = r.Header.Clone()
-
-			resp, err := h.Client.Do(req)
-			if err != nil {
-				log.Printf("Failed to forward webhook for %s: %v", id, err)
-				return
-			}
-			defer resp.Body.Close()
-			log.Printf("Webhook for %s forwarded to %s, status: %d", id, forwardURL, resp.StatusCode)
-		}()
-	}
-
-	h.respondWithJSON(w, http.StatusOK, map[string]string{"status": "Webhook received"})
-}
-
 // --- Protected Handlers ---
 
+// maxWebhookLifetime bounds how far ahead an ephemeral webhook can expire.
+const maxWebhookLifetime = 365 * 24 * time.Hour
+
 type CreateRequest struct {
 	Name       string `json:"name"`
 	SourceType string `json:"source_type"`
+	// TTLSeconds or ExpiresAt make the webhook ephemeral: once it expires it
+	// stops accepting requests and is deleted.
+	TTLSeconds int        `json:"ttl_seconds,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
 }
 
 func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
@@ -176,6 +173,23 @@ func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expiresAt := req.ExpiresAt
+	switch {
+	case req.TTLSeconds != 0 && expiresAt != nil:
+		h.respondWithError(w, http.StatusBadRequest, "Set either ttl_seconds or expires_at, not both")
+		return
+	case req.TTLSeconds < 0:
+		h.respondWithError(w, http.StatusBadRequest, "ttl_seconds must be positive")
+		return
+	case req.TTLSeconds > 0:
+		t := time.Now().Add(time.Duration(req.TTLSeconds) * time.Second)
+		expiresAt = &t
+	}
+	if expiresAt != nil && (!expiresAt.After(time.Now()) || time.Until(*expiresAt) > maxWebhookLifetime) {
+		h.respondWithError(w, http.StatusBadRequest, "Expiry must be in the future and within a year")
+		return
+	}
+
 	id, err := utils.GenerateID(12)
 	if err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate ID")
@@ -183,15 +197,19 @@ func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// The forward URL is now an empty string by default
-	if err := h.DB.CreateWebhook(r.Context(), id, userID, "", req.Name, req.SourceType); err != nil {
+	if err := h.DB.CreateWebhook(r.Context(), id, userID, "", req.Name, req.SourceType, expiresAt); err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to create webhook")
 		return
 	}
+	h.webhooks.invalidate(id)
 
 	resp := map[string]string{
-		"webhook_url": fmt.Sprintf("%s/webhook/%s", h.BaseURL, id),
+		"webhook_url": fmt.Sprintf("%s/hooks/%s", h.BaseURL, id),
 		"inspect_url": fmt.Sprintf("%s/inspect/%s", h.BaseURL, id),
 	}
+	if expiresAt != nil {
+		resp["expires_at"] = expiresAt.UTC().Format(time.RFC3339)
+	}
 	h.respondWithJSON(w, http.StatusCreated, resp)
 }
 
@@ -245,6 +263,7 @@ func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to delete webhook")
 		return
 	}
+	h.webhooks.invalidate(webhookID)
 
 	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted successfully"})
 }
@@ -267,6 +286,7 @@ func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		ForwardURL string `json:"forward_url"`
 		Name       string `json:"name"`
+		SourceType string `json:"source_type"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -274,16 +294,27 @@ func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.ForwardURL != "" {
+		if err := h.Forwarder.Outbound.CheckURL(r.Context(), req.ForwardURL); err != nil {
+			h.respondWithError(w, http.StatusBadRequest, "Invalid forward_url: "+err.Error())
+			return
+		}
+	}
+
 	// Update the webhook
-	err = h.DB.UpdateWebhook(r.Context(), webhookID, userID, req.ForwardURL, req.Name)
+	err = h.DB.UpdateWebhook(r.Context(), webhookID, userID, req.ForwardURL, req.Name, req.SourceType)
 	if err != nil {
 		h.respondWithError(w, http.StatusInternalServerError, "Failed to update webhook")
 		return
 	}
+	h.webhooks.invalidate(webhookID)
 
 	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Webhook updated successfully"})
 }
 
+// InspectWebhook returns a page of the webhook's captured requests, newest
+// first, narrowed by the filters in the query string. Pass next_cursor back
+// as cursor to get the following page.
 func (h *Handler) InspectWebhook(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userContextKey).(string)
 	webhookID := chi.URLParam(r, "id")
@@ -300,13 +331,15 @@ func (h *Handler) InspectWebhook(w http.ResponseWriter, r *http.Request) {
 	}
 	// --- END OF BLOCK ---
 
-	events, err := h.DB.GetRequests(r.Context(), webhookID, 100)
+	filter, limit, err := parseRequestQuery(r)
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to get webhook requests")
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	h.respondWithJSON(w, http.StatusOK, events)
+	// One extra row tells whether there is another page.
+	events, err := h.DB.GetRequests(r.Context(), webhookID, filter, limit+1)
+	h.respondWithRequestPage(w, events, limit, err)
 }
 
 func (h *Handler) ClearWebhookRequests(w http.ResponseWriter, r *http.Request) {
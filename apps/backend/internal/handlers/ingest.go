@@ -0,0 +1,461 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"hookinator/internal/database"
+	"hookinator/internal/ingestauth"
+	"hookinator/internal/responder"
+	"hookinator/internal/schema"
+	"hookinator/internal/stubs"
+	"hookinator/internal/utils"
+	"hookinator/internal/verify"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Reasons an incoming request can be rejected, as recorded per webhook ID.
+const (
+	rejectNotFound         = "not_found"
+	rejectGone             = "gone"
+	rejectInvalidSignature = "invalid_signature"
+	rejectInvalidPayload   = "invalid_payload"
+	rejectExpired          = "expired"
+)
+
+// ingestError is the payload returned to senders whose request was refused.
+type ingestError struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	WebhookID string `json:"webhook_id"`
+	// ValidationErrors explains an invalid_payload rejection.
+	ValidationErrors []database.ValidationError `json:"validation_errors,omitempty"`
+}
+
+// rejectWebhook refuses an incoming request and counts the rejection against
+// the webhook ID.
+func (h *Handler) rejectWebhook(w http.ResponseWriter, r *http.Request, id string, code int, reason, message string) {
+	h.reject(w, r, code, ingestError{Error: message, Code: reason, WebhookID: id})
+}
+
+// reject is rejectWebhook with a prepared payload.
+func (h *Handler) reject(w http.ResponseWriter, r *http.Request, code int, payload ingestError) {
+	h.Rejections.Record(payload.WebhookID, payload.Code)
+	log.Printf("Rejected %s request for webhook %s from %s: %s", r.Method, payload.WebhookID, utils.ClientIP(r, h.TrustedProxies), payload.Code)
+	h.respondWithJSON(w, code, payload)
+}
+
+// blockRequest refuses a request to an existing webhook and logs it for the
+// owner, so probing shows up rather than being dropped silently.
+func (h *Handler) blockRequest(w http.ResponseWriter, r *http.Request, id, clientIP string, code int, reason, message string) {
+	var subPath string
+	if rest := chi.URLParam(r, "*"); rest != "" {
+		subPath = "/" + rest
+	}
+	blocked := database.BlockedRequest{
+		WebhookID: id,
+		Reason:    reason,
+		RemoteIP:  clientIP,
+		Method:    strings.ToValidUTF8(r.Method, ""),
+		Path:      strings.ToValidUTF8(subPath, ""),
+		UserAgent: strings.ToValidUTF8(r.UserAgent(), ""),
+	}
+	if err := h.DB.SaveBlockedRequest(r.Context(), blocked); err != nil {
+		log.Printf("%v", err)
+	}
+	h.rejectWebhook(w, r, id, code, reason, message)
+}
+
+// HandleWebhook receives and stores an incoming webhook and queues it for
+// delivery to the forward URL.
+func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	// The per-IP limit applies before the lookup, so senders probing random
+	// IDs cannot turn every request into a database query.
+	clientIP := utils.ClientIP(r, h.TrustedProxies)
+	if !h.allowRate(w, r, id, "ip:"+clientIP, h.Limits.PerIP, rejectRateLimitedIP) {
+		return
+	}
+
+	webhook, err := h.lookupWebhook(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.rejectWebhook(w, r, id, http.StatusNotFound, rejectNotFound, "Webhook not found")
+		case errors.Is(err, database.ErrWebhookGone):
+			h.rejectWebhook(w, r, id, http.StatusGone, rejectGone, "Webhook has been deleted")
+		case errors.Is(err, database.ErrWebhookExpired):
+			h.rejectWebhook(w, r, id, http.StatusGone, rejectExpired, "Webhook has expired")
+		default:
+			log.Printf("Failed to look up webhook %s: %v", id, err)
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to look up webhook")
+		}
+		return
+	}
+
+	// The reaper deletes expired webhooks only periodically, so expiry is
+	// enforced here too.
+	if webhook.ExpiresAt != nil && !time.Now().Before(*webhook.ExpiresAt) {
+		h.rejectWebhook(w, r, id, http.StatusGone, rejectExpired, "Webhook has expired")
+		return
+	}
+
+	if webhook.Status.Status == database.WebhookDisabled {
+		h.rejectDisabledWebhook(w, r, webhook)
+		return
+	}
+
+	if webhook.ipFilter != nil {
+		if reason := webhook.ipFilter.Check(clientIP); reason != "" {
+			h.blockRequest(w, r, id, clientIP, http.StatusForbidden, reason, "Requests from this address are not allowed")
+			return
+		}
+	}
+
+	if !h.allowRate(w, r, id, "webhook:"+id, h.webhookLimit(webhook), rejectRateLimitedWebhook) {
+		return
+	}
+
+	// Credentials are checked before the body is read and stripped from what
+	// is captured, so they are neither stored nor forwarded.
+	header, rawQuery := r.Header, r.URL.RawQuery
+	if auth := webhook.IngestAuth; auth != nil {
+		if reason := ingestauth.Check(*auth, r); reason != "" {
+			switch auth.Mode {
+			case ingestauth.ModeBasic:
+				w.Header().Set("WWW-Authenticate", `Basic realm="hookinator"`)
+			case ingestauth.ModeBearer:
+				w.Header().Set("WWW-Authenticate", `Bearer realm="hookinator"`)
+			}
+			h.blockRequest(w, r, id, clientIP, http.StatusUnauthorized, reason, "Authentication required")
+			return
+		}
+		header, rawQuery = ingestauth.StripCredentials(*auth, header, rawQuery)
+	}
+
+	if limit := h.Limits.MaxBodyBytes; limit > 0 {
+		if r.ContentLength > limit {
+			h.refuseTooLarge(w, id)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.refuseTooLarge(w, id)
+			return
+		}
+		h.respondWithError(w, http.StatusInternalServerError, "Cannot read request body")
+		return
+	}
+	defer r.Body.Close()
+
+	// Anything after the webhook ID is the sub-path the sender appended.
+	var subPath string
+	if rest := chi.URLParam(r, "*"); rest != "" {
+		subPath = "/" + rest
+	}
+
+	webhookReq := database.WebhookRequest{
+		Timestamp:     time.Now(),
+		Method:        r.Method,
+		Path:          subPath,
+		Query:         rawQuery,
+		Headers:       header,
+		Body:          string(bodyBytes),
+		RemoteIP:      clientIP,
+		RemoteAddr:    r.RemoteAddr,
+		Host:          r.Host,
+		Proto:         r.Proto,
+		ContentLength: r.ContentLength,
+		BodySize:      int64(len(bodyBytes)),
+	}
+	if r.TLS != nil {
+		webhookReq.TLSVersion = tls.VersionName(r.TLS.Version)
+		webhookReq.TLSServerName = r.TLS.ServerName
+	}
+
+	if !h.verifySignature(w, r, webhook, &webhookReq, bodyBytes) {
+		return
+	}
+
+	skipDelivery := webhook.Status.Status == database.WebhookCaptureOnly
+	if webhook.schema != nil {
+		webhookReq.ValidationErrors = webhook.schema.Validate(bodyBytes)
+		if len(webhookReq.ValidationErrors) > 0 {
+			switch webhook.schema.Action() {
+			case schema.ActionReject:
+				h.reject(w, r, http.StatusUnprocessableEntity, ingestError{
+					Error:            "Payload does not match the webhook's schema",
+					Code:             rejectInvalidPayload,
+					WebhookID:        id,
+					ValidationErrors: webhookReq.ValidationErrors,
+				})
+				return
+			case schema.ActionSkipForwarding:
+				skipDelivery = true
+			}
+		}
+	}
+
+	data := responder.NewData(webhook.ID, webhookReq)
+	if webhook.dedup != nil {
+		webhookReq.DedupKey = webhook.dedup.Key(r.Header, data.JSON)
+	}
+	if webhook.eventType != nil {
+		webhookReq.EventType = webhook.eventType.Extract(r.Header, data.JSON)
+	}
+
+	stub, err := h.matchStub(r, webhook, data)
+	if err != nil {
+		log.Printf("Failed to match stubs of webhook %s: %v", id, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to match stubs")
+		return
+	}
+	if stub != nil {
+		webhookReq.StubID = &stub.ID
+	}
+
+	saveOpts := database.SaveOptions{SkipDelivery: skipDelivery}
+	if webhook.dedup != nil {
+		saveOpts.DedupWindow = webhook.dedup.Window()
+	}
+	if h.Ingest != nil {
+		// The buffer saves the request and queues its deliveries shortly;
+		// when it is full the sender is asked to retry rather than waiting.
+		if err := h.Ingest.Submit(id, webhookReq, saveOpts); err != nil {
+			log.Printf("Refusing request for webhook %s: %v", id, err)
+			w.Header().Set("Retry-After", "1")
+			h.respondWithError(w, http.StatusServiceUnavailable, "Server is overloaded, retry later")
+			return
+		}
+		releaseIngestSlot(r)
+		h.reply(w, r, webhook, stub, webhookReq)
+		return
+	}
+
+	// The request and its delivery job are stored together, so once this
+	// succeeds the forwarder is guaranteed to pick it up, even after a restart.
+	if err := h.DB.SaveRequest(r.Context(), id, &webhookReq, saveOpts); err != nil {
+		if errors.Is(err, database.ErrWebhookGone) {
+			h.webhooks.invalidate(id)
+			// The reaper may have deleted it since it was looked up.
+			if webhook.ExpiresAt != nil && !time.Now().Before(*webhook.ExpiresAt) {
+				h.rejectWebhook(w, r, id, http.StatusGone, rejectExpired, "Webhook has expired")
+			} else {
+				h.rejectWebhook(w, r, id, http.StatusGone, rejectGone, "Webhook has been deleted")
+			}
+			return
+		}
+		log.Printf("Failed to save webhook request: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to store webhook request")
+		return
+	}
+	switch {
+	case webhookReq.DuplicateOf != nil:
+		log.Printf("Request %d for webhook %s duplicates request %d, not forwarding", webhookReq.ID, id, *webhookReq.DuplicateOf)
+	case skipDelivery:
+		log.Printf("Request %d for webhook %s captured without forwarding", webhookReq.ID, id)
+	default:
+		h.Forwarder.Notify()
+	}
+
+	releaseIngestSlot(r)
+	h.reply(w, r, webhook, stub, webhookReq)
+}
+
+// reply answers the sender of a captured request, as the matched stub or
+// the webhook's response dictates.
+func (h *Handler) reply(w http.ResponseWriter, r *http.Request, webhook *ingestConfig, stub *stubs.Stub, req database.WebhookRequest) {
+	if stub != nil {
+		h.respondWithStub(w, r, webhook, stub, req)
+		return
+	}
+	h.respond(w, r, webhook, req)
+}
+
+// verifySignature records on req whether its signature is valid for the
+// webhook's source type. It returns false if the request was rejected.
+func (h *Handler) verifySignature(w http.ResponseWriter, r *http.Request, webhook *ingestConfig, req *database.WebhookRequest, body []byte) bool {
+	if webhook.verifier == nil {
+		return true
+	}
+
+	vreq := &verify.Request{
+		URL:      h.BaseURL + r.URL.RequestURI(),
+		Header:   r.Header,
+		Body:     body,
+		Received: req.Timestamp,
+	}
+	tolerance := time.Duration(webhook.Verification.ToleranceSeconds) * time.Second
+	err := errors.New("no verification secret configured")
+	for _, secret := range webhook.verifySecrets {
+		if err = webhook.verifier.Verify(vreq, secret, tolerance); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		if webhook.Verification.RejectInvalid {
+			h.rejectWebhook(w, r, webhook.ID, http.StatusUnauthorized, rejectInvalidSignature, "Invalid signature: "+err.Error())
+			return false
+		}
+		req.SignatureStatus = verify.StatusInvalid
+		req.SignatureError = err.Error()
+		return true
+	}
+	req.SignatureStatus = verify.StatusValid
+	return true
+}
+
+// matchStub returns the first of the webhook's stubs that matches the
+// request, or nil if none does. Scenario states are only fetched when a stub
+// depends on them.
+func (h *Handler) matchStub(r *http.Request, webhook *ingestConfig, data responder.Data) (*stubs.Stub, error) {
+	if len(webhook.stubs) == 0 {
+		return nil, nil
+	}
+
+	var states map[string]string
+	if stubs.UsesScenarios(webhook.stubs) {
+		scenarios, err := h.DB.GetScenarios(r.Context(), webhook.ID)
+		if err != nil {
+			return nil, err
+		}
+		states = make(map[string]string, len(scenarios))
+		for _, sc := range scenarios {
+			states[sc.Name] = sc.State
+		}
+	}
+
+	return stubs.Select(webhook.stubs, data, states), nil
+}
+
+// respondWithStub answers the sender as a matched stub dictates: with a
+// simulated fault or with the stub's response.
+func (h *Handler) respondWithStub(w http.ResponseWriter, r *http.Request, webhook *ingestConfig, stub *stubs.Stub, req database.WebhookRequest) {
+	hits, err := h.DB.RecordStubHit(r.Context(), stub.Stub)
+	if err != nil {
+		log.Printf("%v", err)
+	}
+
+	if fault := stub.Fault; fault != nil {
+		switch fault.Type {
+		case stubs.FaultTimeout:
+			select {
+			case <-time.After(time.Duration(fault.DurationMS) * time.Millisecond):
+			case <-r.Context().Done():
+			}
+			// Aborting the handler closes the connection without a response.
+			panic(http.ErrAbortHandler)
+		case stubs.FaultConnectionReset, stubs.FaultEmptyResponse:
+			h.dropConnection(w, fault.Type == stubs.FaultConnectionReset)
+			return
+		case stubs.FaultErrorBurst:
+			if stub.InBurst(hits) {
+				status := fault.Status
+				if status == 0 {
+					status = http.StatusServiceUnavailable
+				}
+				h.respondWithError(w, status, "Simulated error burst")
+				return
+			}
+		}
+	}
+
+	if delay := stub.Response().Delay(); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if err := stub.Response().Write(w, responder.NewData(webhook.ID, req)); err != nil {
+		log.Printf("Failed to render response of stub %d: %v", stub.ID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to render stub response: "+err.Error())
+	}
+}
+
+// dropConnection closes the sender's connection without writing a response.
+// With reset set, the TCP connection is reset rather than closed cleanly.
+func (h *Handler) dropConnection(w http.ResponseWriter, reset bool) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		// HTTP/2 streams cannot be hijacked; aborting resets the stream.
+		panic(http.ErrAbortHandler)
+	}
+	raw := conn
+	if tc, ok := conn.(*tls.Conn); ok {
+		raw = tc.NetConn()
+	}
+	if tcp, ok := raw.(*net.TCPConn); ok && reset {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// respond answers the sender with the webhook's configured response, or the
+// default acknowledgement if it has none.
+func (h *Handler) respond(w http.ResponseWriter, r *http.Request, webhook *ingestConfig, req database.WebhookRequest) {
+	if webhook.response == nil {
+		h.respondWithJSON(w, http.StatusOK, map[string]string{"status": "Webhook received"})
+		return
+	}
+
+	if delay := webhook.response.Delay(); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if err := webhook.response.Write(w, responder.NewData(webhook.ID, req)); err != nil {
+		log.Printf("Failed to render response for webhook %s: %v", webhook.ID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to render configured response: "+err.Error())
+	}
+}
+
+// ListRejections returns how many incoming requests were refused for each of
+// the user's current and deleted webhooks. With ?webhook_id= it returns the
+// counters of that ID only, which may be one that never existed, so that
+// senders using a mistyped URL can be spotted.
+func (h *Handler) ListRejections(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+
+	rejections, err := h.DB.GetRejectionsForUser(r.Context(), userID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve rejections")
+		return
+	}
+
+	if webhookID := r.URL.Query().Get("webhook_id"); webhookID != "" {
+		own := rejections[:0]
+		for _, rj := range rejections {
+			if rj.WebhookID == webhookID {
+				own = append(own, rj)
+			}
+		}
+		rejections = own
+		if len(rejections) == 0 {
+			rejections, err = h.DB.GetUnknownRejections(r.Context(), webhookID)
+			if err != nil {
+				h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve rejections")
+				return
+			}
+		}
+	}
+
+	h.respondWithJSON(w, http.StatusOK, rejections)
+}
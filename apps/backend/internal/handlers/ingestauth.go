@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"hookinator/internal/database"
+	"hookinator/internal/ingestauth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ingestAuthRequest sets how senders authenticate. A password or token left
+// empty is generated.
+type ingestAuthRequest struct {
+	Mode         string   `json:"mode"`
+	Username     string   `json:"username"`
+	Password     string   `json:"password"`
+	Token        string   `json:"token"`
+	QueryParam   string   `json:"query_param"`
+	Fingerprints []string `json:"fingerprints"`
+}
+
+// ingestAuthView is an ingestion auth config as shown to its owner. Secret
+// is only set in the response that generated it; afterwards only its hash is
+// kept.
+type ingestAuthView struct {
+	Mode         string   `json:"mode"`
+	Username     string   `json:"username,omitempty"`
+	QueryParam   string   `json:"query_param,omitempty"`
+	Fingerprints []string `json:"fingerprints,omitempty"`
+	Secret       string   `json:"secret,omitempty"`
+}
+
+func newIngestAuthView(cfg *database.IngestAuthConfig) *ingestAuthView {
+	if cfg == nil {
+		return nil
+	}
+	view := &ingestAuthView{
+		Mode:         cfg.Mode,
+		Username:     cfg.Username,
+		QueryParam:   cfg.QueryParam,
+		Fingerprints: cfg.Fingerprints,
+	}
+	if cfg.Mode == ingestauth.ModeQuery && view.QueryParam == "" {
+		view.QueryParam = ingestauth.DefaultQueryParam
+	}
+	return view
+}
+
+// GetIngestAuth returns how senders must authenticate, or null if the
+// webhook is public.
+func (h *Handler) GetIngestAuth(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	cfg, err := h.DB.GetIngestAuth(r.Context(), webhookID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve ingestion auth")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, newIngestAuthView(cfg))
+}
+
+// UpdateIngestAuth requires senders to authenticate with HTTP Basic, a
+// bearer token, a secret query parameter or a pinned client certificate.
+// Generated passwords and tokens are returned once.
+func (h *Handler) UpdateIngestAuth(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	var req ingestAuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	cfg := database.IngestAuthConfig{Mode: req.Mode}
+	var secret string
+	var generated bool
+	switch req.Mode {
+	case ingestauth.ModeBasic, ingestauth.ModeBearer, ingestauth.ModeQuery:
+		secret = req.Token
+		if req.Mode == ingestauth.ModeBasic {
+			cfg.Username = req.Username
+			secret = req.Password
+		}
+		if req.Mode == ingestauth.ModeQuery {
+			cfg.QueryParam = req.QueryParam
+		}
+		if secret == "" {
+			var err error
+			if secret, err = ingestauth.Generate(); err != nil {
+				log.Printf("%v", err)
+				h.respondWithError(w, http.StatusInternalServerError, "Failed to generate secret")
+				return
+			}
+			generated = true
+		} else if len(secret) < ingestauth.MinSecretLength {
+			h.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Password or token must be at least %d characters", ingestauth.MinSecretLength))
+			return
+		}
+		cfg.SecretHash = ingestauth.Hash(secret)
+	case ingestauth.ModeMTLS:
+		for _, f := range req.Fingerprints {
+			normalized, err := ingestauth.NormalizeFingerprint(f)
+			if err != nil {
+				h.respondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			cfg.Fingerprints = append(cfg.Fingerprints, normalized)
+		}
+	}
+	if err := ingestauth.Validate(cfg); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.DB.UpdateIngestAuth(r.Context(), webhookID, userID, &cfg); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update ingestion auth")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	view := newIngestAuthView(&cfg)
+	if generated {
+		view.Secret = secret
+	}
+	h.respondWithJSON(w, http.StatusOK, view)
+}
+
+// RemoveIngestAuth makes the webhook public again.
+func (h *Handler) RemoveIngestAuth(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	if err := h.DB.UpdateIngestAuth(r.Context(), webhookID, userID, nil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to remove ingestion auth")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Ingestion auth removed"})
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"hookinator/internal/database"
+)
+
+const (
+	defaultRequestPageSize = 100
+	maxRequestPageSize     = 1000
+)
+
+// requestPage is one page of a webhook's captured requests. NextCursor is
+// null on the last page.
+type requestPage struct {
+	Requests   []database.WebhookRequest `json:"requests"`
+	NextCursor *string                   `json:"next_cursor"`
+}
+
+// parseRequestQuery reads the filters, cursor and page size of a request
+// listing from the query string.
+func parseRequestQuery(r *http.Request) (database.RequestFilter, int, error) {
+	q := r.URL.Query()
+	filter := database.RequestFilter{
+		Method:       strings.ToUpper(q.Get("method")),
+		EventType:    q.Get("event_type"),
+		Header:       http.CanonicalHeaderKey(q.Get("header")),
+		HeaderValue:  q.Get("header_value"),
+		BodyContains: q.Get("body_contains"),
+		Text:         strings.TrimSpace(q.Get("q")),
+		JSONPath:     strings.TrimSpace(q.Get("jsonpath")),
+	}
+	if filter.HeaderValue != "" && filter.Header == "" {
+		return filter, 0, errors.New("header_value requires header")
+	}
+	for _, t := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if raw := q.Get(t.name); raw != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, raw)
+			if err != nil {
+				return filter, 0, fmt.Errorf("%s must be an RFC 3339 timestamp", t.name)
+			}
+			*t.dst = parsed
+		}
+	}
+	if raw := q.Get("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil {
+			return filter, 0, err
+		}
+		filter.After = &cursor
+	}
+
+	limit := defaultRequestPageSize
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxRequestPageSize {
+			return filter, 0, fmt.Errorf("limit must be between 1 and %d", maxRequestPageSize)
+		}
+		limit = n
+	}
+	return filter, limit, nil
+}
+
+// respondWithRequestPage writes the result of a request listing that asked
+// for limit+1 rows.
+func (h *Handler) respondWithRequestPage(w http.ResponseWriter, events []database.WebhookRequest, limit int, err error) {
+	if errors.Is(err, database.ErrInvalidJSONPath) {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("failed to get webhook requests: %v", err)
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to get webhook requests")
+		return
+	}
+
+	page := requestPage{Requests: events}
+	if len(events) > limit {
+		page.Requests = events[:limit]
+		cursor := encodeCursor(page.Requests[limit-1])
+		page.NextCursor = &cursor
+	}
+	if page.Requests == nil {
+		page.Requests = []database.WebhookRequest{}
+	}
+	h.respondWithJSON(w, http.StatusOK, page)
+}
+
+// SearchRequests searches the requests captured by all of the user's
+// webhooks, or by one with ?webhook_id=. It takes the same parameters as
+// InspectWebhook, typically q for free text over bodies and headers and
+// jsonpath for a predicate on JSON bodies.
+func (h *Handler) SearchRequests(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+
+	filter, limit, err := parseRequestQuery(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhookID := r.URL.Query().Get("webhook_id")
+	if webhookID == "" {
+		events, err := h.DB.SearchRequests(r.Context(), userID, filter, limit+1)
+		h.respondWithRequestPage(w, events, limit, err)
+		return
+	}
+	exists, err := h.DB.CheckWebhookOwnership(r.Context(), webhookID, userID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to verify webhook ownership")
+		return
+	}
+	if !exists {
+		h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	events, err := h.DB.GetRequests(r.Context(), webhookID, filter, limit+1)
+	h.respondWithRequestPage(w, events, limit, err)
+}
+
+// encodeCursor returns an opaque cursor pointing after a request.
+func encodeCursor(req database.WebhookRequest) string {
+	raw := strconv.FormatInt(req.Timestamp.UnixMicro(), 10) + ":" + strconv.FormatInt(req.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (database.RequestCursor, error) {
+	invalid := errors.New("invalid cursor")
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return database.RequestCursor{}, invalid
+	}
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return database.RequestCursor{}, invalid
+	}
+	us, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return database.RequestCursor{}, invalid
+	}
+	requestID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return database.RequestCursor{}, invalid
+	}
+	return database.RequestCursor{ReceivedAt: time.UnixMicro(us), ID: requestID}, nil
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"hookinator/internal/database"
+	"hookinator/internal/ipfilter"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultBlockedLimit = 100
+	maxBlockedLimit     = 1000
+)
+
+// GetIPFilter returns the webhook's IP filter, or null if every address is
+// allowed.
+func (h *Handler) GetIPFilter(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	cfg, err := h.DB.GetIPFilter(r.Context(), webhookID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve IP filter")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, cfg)
+}
+
+// UpdateIPFilter sets the allowlist, denylist and provider presets that
+// decide which client addresses may send requests.
+func (h *Handler) UpdateIPFilter(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	var cfg database.IPFilterConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if _, err := ipfilter.Compile(cfg); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.DB.UpdateIPFilter(r.Context(), webhookID, userID, &cfg); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update IP filter")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, cfg)
+}
+
+// RemoveIPFilter allows requests from every address again.
+func (h *Handler) RemoveIPFilter(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	if err := h.DB.UpdateIPFilter(r.Context(), webhookID, userID, nil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to remove IP filter")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "IP filter removed"})
+}
+
+// ListIPPresets returns the names of the bundled provider presets.
+func (h *Handler) ListIPPresets(w http.ResponseWriter, r *http.Request) {
+	h.respondWithJSON(w, http.StatusOK, ipfilter.Presets())
+}
+
+// ListBlockedRequests returns the most recent requests refused before being
+// captured, such as by the IP filter or failed authentication. The limit
+// query parameter defaults to 100; reason narrows the list to one kind.
+func (h *Handler) ListBlockedRequests(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	limit := defaultBlockedLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxBlockedLimit {
+			h.respondWithError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxBlockedLimit))
+			return
+		}
+		limit = n
+	}
+
+	blocked, err := h.DB.GetBlockedRequests(r.Context(), chi.URLParam(r, "id"), r.URL.Query().Get("reason"), limit)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve blocked requests")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, blocked)
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"hookinator/internal/database"
+	"hookinator/internal/ratelimit"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Reasons an incoming request is refused to protect the server. They are
+// not recorded as rejections: a flood would turn into as many writes.
+const (
+	rejectRateLimitedWebhook = "rate_limited_webhook"
+	rejectRateLimitedIP      = "rate_limited_ip"
+	rejectPayloadTooLarge    = "payload_too_large"
+)
+
+const (
+	maxRequestsPerSecond = 10000
+	maxBurst             = 100000
+)
+
+// IngestLimits protects ingestion from misbehaving senders and overload.
+type IngestLimits struct {
+	// Limiter holds the token buckets; nil disables rate limiting.
+	Limiter ratelimit.Limiter
+	// PerWebhook is the default limit of each webhook ID; a webhook's own
+	// rate limit overrides it.
+	PerWebhook ratelimit.Limit
+	// PerIP limits each sender address across all webhook IDs. Providers
+	// send from shared addresses, so it is off unless configured, and it
+	// needs the proxies in front of the server to be trusted.
+	PerIP ratelimit.Limit
+	// MaxBodyBytes caps the size of a request body; larger requests are
+	// refused with 413. 0 means no cap.
+	MaxBodyBytes int64
+	// MaxConcurrent caps the requests being ingested at once; beyond it
+	// requests are shed with 503. 0 means no cap.
+	MaxConcurrent int
+}
+
+// ShedLoad refuses ingestion requests with 503 while MaxConcurrent are
+// already in progress, before they can queue for database connections.
+// A request holds its slot until it calls releaseIngestSlot or returns.
+func (h *Handler) ShedLoad(next http.Handler) http.Handler {
+	if h.ingestSlots == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case h.ingestSlots <- struct{}{}:
+			var once sync.Once
+			release := func() { once.Do(func() { <-h.ingestSlots }) }
+			defer release()
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ingestSlotContextKey, release)))
+		default:
+			w.Header().Set("Retry-After", "1")
+			h.respondWithError(w, http.StatusServiceUnavailable, "Server is overloaded, retry later")
+		}
+	})
+}
+
+// releaseIngestSlot gives back the slot ShedLoad took for r once the request
+// is done with the database, so that configured response delays and
+// simulated faults do not count against the cap.
+func releaseIngestSlot(r *http.Request) {
+	if release, ok := r.Context().Value(ingestSlotContextKey).(func()); ok {
+		release()
+	}
+}
+
+// allowRate takes a token from the bucket named key, answering 429 with
+// Retry-After if none is left. Limiter failures let the request through.
+func (h *Handler) allowRate(w http.ResponseWriter, r *http.Request, id, key string, limit ratelimit.Limit, reason string) bool {
+	if h.Limits.Limiter == nil || limit.Rate <= 0 {
+		return true
+	}
+	result, err := h.Limits.Limiter.Allow(r.Context(), key, limit)
+	if err != nil {
+		log.Printf("Rate limiter failed for %s: %v", key, err)
+		return true
+	}
+	if result.Allowed {
+		return true
+	}
+
+	seconds := int(math.Ceil(result.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	h.respondWithJSON(w, http.StatusTooManyRequests, ingestError{Error: "Rate limit exceeded", Code: reason, WebhookID: id})
+	return false
+}
+
+// refuseTooLarge answers a request whose body exceeds MaxBodyBytes.
+func (h *Handler) refuseTooLarge(w http.ResponseWriter, id string) {
+	message := fmt.Sprintf("Request body exceeds %d bytes", h.Limits.MaxBodyBytes)
+	h.respondWithJSON(w, http.StatusRequestEntityTooLarge, ingestError{Error: message, Code: rejectPayloadTooLarge, WebhookID: id})
+}
+
+// webhookLimit is the limit that applies to a webhook.
+func (h *Handler) webhookLimit(webhook *ingestConfig) ratelimit.Limit {
+	if webhook.RateLimit != nil {
+		return ratelimit.Limit{Rate: webhook.RateLimit.RequestsPerSecond, Burst: webhook.RateLimit.Burst}
+	}
+	return h.Limits.PerWebhook
+}
+
+// GetRateLimit returns the webhook's ingestion limit and whether it is the
+// server default.
+func (h *Handler) GetRateLimit(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	cfg, err := h.DB.GetRateLimit(r.Context(), webhookID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve rate limit")
+		}
+		return
+	}
+
+	resp := struct {
+		database.RateLimitConfig
+		Default bool `json:"default"`
+	}{Default: cfg == nil}
+	if cfg != nil {
+		resp.RateLimitConfig = *cfg
+	} else {
+		resp.RequestsPerSecond = h.Limits.PerWebhook.Rate
+		resp.Burst = h.Limits.PerWebhook.Burst
+	}
+	h.respondWithJSON(w, http.StatusOK, resp)
+}
+
+// UpdateRateLimit sets the webhook's own ingestion limit.
+func (h *Handler) UpdateRateLimit(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	var cfg database.RateLimitConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if cfg.RequestsPerSecond <= 0 || cfg.RequestsPerSecond > maxRequestsPerSecond {
+		h.respondWithError(w, http.StatusBadRequest, "requests_per_second must be above 0 and at most "+strconv.Itoa(maxRequestsPerSecond))
+		return
+	}
+	if cfg.Burst < 1 || cfg.Burst > maxBurst {
+		h.respondWithError(w, http.StatusBadRequest, "burst must be between 1 and "+strconv.Itoa(maxBurst))
+		return
+	}
+
+	if err := h.DB.UpdateRateLimit(r.Context(), webhookID, userID, &cfg); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update rate limit")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, cfg)
+}
+
+// ResetRateLimit restores the server default limit.
+func (h *Handler) ResetRateLimit(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	if err := h.DB.UpdateRateLimit(r.Context(), webhookID, userID, nil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to reset rate limit")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Rate limit reset to default"})
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"hookinator/internal/database"
+	"hookinator/internal/forwarder"
+	"hookinator/internal/outbound"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxBulkReplay = 1000
+
+type replayRequest struct {
+	URL     string      `json:"url"`
+	Method  string      `json:"method"`
+	Headers http.Header `json:"headers"`
+	Body    *string     `json:"body"`
+}
+
+// options validates the overrides. An override URL is held to the same
+// policy as destinations, since the caller sees what it responds.
+func (req replayRequest) options(ctx context.Context, policy *outbound.Policy) (forwarder.ReplayOptions, string) {
+	if req.URL != "" {
+		u, err := url.Parse(req.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return forwarder.ReplayOptions{}, "url must be an absolute http(s) URL"
+		}
+		if err := policy.CheckURL(ctx, req.URL); err != nil {
+			return forwarder.ReplayOptions{}, "url: " + err.Error()
+		}
+	}
+	return forwarder.ReplayOptions{
+		URL:     req.URL,
+		Method:  req.Method,
+		Headers: req.Headers,
+		Body:    req.Body,
+	}, ""
+}
+
+// decodeOptionalJSON decodes a JSON body into v, treating an empty body as {}.
+func decodeOptionalJSON(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// ReplayRequest re-sends a captured request to the webhook's forward URL and
+// destinations, or to an override URL, and returns the resulting deliveries.
+func (h *Handler) ReplayRequest(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+
+	requestID, ok := h.requestID(w, r, webhookID)
+	if !ok {
+		return
+	}
+
+	var req replayRequest
+	if err := decodeOptionalJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	opts, problem := req.options(r.Context(), h.Forwarder.Outbound)
+	if problem != "" {
+		h.respondWithError(w, http.StatusBadRequest, problem)
+		return
+	}
+
+	stored, err := h.DB.GetRequest(r.Context(), webhookID, requestID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Request not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to get webhook request")
+		}
+		return
+	}
+
+	deliveries, err := h.Forwarder.Replay(r.Context(), webhookID, stored, opts)
+	if err != nil {
+		if errors.Is(err, forwarder.ErrNoTargets) {
+			h.respondWithError(w, http.StatusBadRequest, "Webhook has no forward URL or enabled destinations; pass a url to replay to")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to replay request")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// BulkReplay re-sends captured requests in their original order in the
+// background. The requests are selected with the same query parameters as
+// InspectWebhook, except cursor, and can be narrowed further to the public
+// or numeric IDs in request_ids. Results show up in each request's
+// deliveries; the replay's progress under GetBulkReplay.
+func (h *Handler) BulkReplay(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+
+	filter, limit, err := parseRequestQuery(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.After != nil {
+		h.respondWithError(w, http.StatusBadRequest, "cursor is not supported for replays")
+		return
+	}
+	if r.URL.Query().Get("limit") == "" {
+		limit = maxBulkReplay
+	}
+
+	var req struct {
+		replayRequest
+		RequestIDs     []string `json:"request_ids"`
+		PreserveTiming bool     `json:"preserve_timing"`
+	}
+	if err := decodeOptionalJSON(r, &req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	opts, problem := req.options(r.Context(), h.Forwarder.Outbound)
+	if problem != "" {
+		h.respondWithError(w, http.StatusBadRequest, problem)
+		return
+	}
+	if len(req.RequestIDs) > maxBulkReplay {
+		h.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("request_ids can list at most %d requests", maxBulkReplay))
+		return
+	}
+	requestIDs := make([]int64, 0, len(req.RequestIDs))
+	for _, ref := range req.RequestIDs {
+		id, err := h.resolveRequestID(r.Context(), webhookID, ref)
+		if err != nil {
+			switch {
+			case errors.Is(err, errInvalidRequestID):
+				h.respondWithError(w, http.StatusBadRequest, "Invalid request ID: "+ref)
+			case errors.Is(err, sql.ErrNoRows):
+				h.respondWithError(w, http.StatusNotFound, "Request not found: "+ref)
+			default:
+				h.respondWithError(w, http.StatusInternalServerError, "Failed to get webhook request")
+			}
+			return
+		}
+		requestIDs = append(requestIDs, id)
+	}
+
+	requests, err := h.DB.GetRequestsForReplay(r.Context(), webhookID, database.ReplayFilter{
+		RequestFilter: filter,
+		RequestIDs:    requestIDs,
+		Limit:         limit,
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidJSONPath) {
+			h.respondWithError(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to get webhook requests")
+		}
+		return
+	}
+	if len(requests) == 0 {
+		h.respondWithError(w, http.StatusNotFound, "No requests match the filter")
+		return
+	}
+
+	replay, err := h.Forwarder.ReplayBatch(r.Context(), webhookID, requests, opts, req.PreserveTiming)
+	if err != nil {
+		switch {
+		case errors.Is(err, forwarder.ErrNoTargets):
+			h.respondWithError(w, http.StatusBadRequest, "Webhook has no forward URL or enabled destinations; pass a url to replay to")
+		case errors.Is(err, forwarder.ErrReplayTooLong):
+			h.respondWithError(w, http.StatusBadRequest, err.Error()+"; narrow the filter or turn off preserve_timing")
+		default:
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to start replay")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+		"message":   "Replay started",
+		"count":     len(requests),
+		"replay_id": replay.ID,
+	})
+}
+
+// GetBulkReplay reports the progress of a bulk replay.
+func (h *Handler) GetBulkReplay(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	replay, err := h.DB.GetBulkReplay(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "replayId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Replay not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to get replay")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, replay)
+}
+
+// CancelBulkReplay stops a running bulk replay before its next request and
+// returns its state. Cancelling a replay that has ended changes nothing.
+func (h *Handler) CancelBulkReplay(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	replay, err := h.DB.CancelBulkReplay(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "replayId"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Replay not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to cancel replay")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, replay)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"hookinator/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// errInvalidRequestID is returned for a request reference that is neither
+// a public nor a numeric ID.
+var errInvalidRequestID = errors.New("invalid request ID")
+
+// resolveRequestID resolves a public ID or, for older clients, the numeric
+// ID to the internal ID of one of the webhook's requests. It returns
+// sql.ErrNoRows if the webhook has no request with that public ID.
+func (h *Handler) resolveRequestID(ctx context.Context, webhookID, ref string) (int64, error) {
+	if !utils.IsULID(ref) {
+		id, err := strconv.ParseInt(ref, 10, 64)
+		if err != nil {
+			return 0, errInvalidRequestID
+		}
+		return id, nil
+	}
+	return h.DB.LookupRequestID(ctx, webhookID, ref)
+}
+
+// requestID resolves the {requestId} URL parameter to the internal ID of
+// one of the webhook's requests. It writes an error response and returns
+// false if there is none.
+func (h *Handler) requestID(w http.ResponseWriter, r *http.Request, webhookID string) (int64, bool) {
+	id, err := h.resolveRequestID(r.Context(), webhookID, chi.URLParam(r, "requestId"))
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidRequestID):
+			h.respondWithError(w, http.StatusBadRequest, "Invalid request ID")
+		case errors.Is(err, sql.ErrNoRows):
+			h.respondWithError(w, http.StatusNotFound, "Request not found")
+		default:
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to get webhook request")
+		}
+		return 0, false
+	}
+	return id, true
+}
+
+// GetWebhookRequest returns one captured request.
+func (h *Handler) GetWebhookRequest(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+	requestID, ok := h.requestID(w, r, webhookID)
+	if !ok {
+		return
+	}
+
+	req, err := h.DB.GetRequest(r.Context(), webhookID, requestID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Request not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to get webhook request")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, req)
+}
+
+// DeleteWebhookRequest deletes one captured request and its deliveries.
+func (h *Handler) DeleteWebhookRequest(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+	requestID, ok := h.requestID(w, r, webhookID)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.DeleteRequest(r.Context(), webhookID, requestID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Request not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to delete webhook request")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Request deleted successfully"})
+}
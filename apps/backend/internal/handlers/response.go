@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"hookinator/internal/database"
+	"hookinator/internal/responder"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetWebhookResponse returns the response configured for senders, or null if
+// the webhook uses the default acknowledgement.
+func (h *Handler) GetWebhookResponse(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	cfg, err := h.DB.GetWebhookResponse(r.Context(), webhookID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve response config")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, cfg)
+}
+
+// UpdateWebhookResponse configures the status, headers, body and delay that
+// senders get back. Headers and body are text/template strings.
+func (h *Handler) UpdateWebhookResponse(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	var cfg database.ResponseConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if cfg.Status == 0 {
+		cfg.Status = http.StatusOK
+	}
+	if _, err := responder.Compile(cfg); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.DB.UpdateWebhookResponse(r.Context(), webhookID, userID, &cfg); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update response config")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, cfg)
+}
+
+// ResetWebhookResponse restores the default acknowledgement.
+func (h *Handler) ResetWebhookResponse(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	if err := h.DB.UpdateWebhookResponse(r.Context(), webhookID, userID, nil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to reset response config")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Response reset to default"})
+}
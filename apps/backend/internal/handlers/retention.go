@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"hookinator/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetRetention returns the retention policy that applies to the webhook and
+// whether it is the server default.
+func (h *Handler) GetRetention(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	policy, err := h.DB.GetRetention(r.Context(), webhookID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve retention policy")
+		}
+		return
+	}
+
+	resp := struct {
+		database.RetentionPolicy
+		Default bool `json:"default"`
+	}{RetentionPolicy: h.Retention, Default: policy == nil}
+	if policy != nil {
+		resp.RetentionPolicy = policy.Or(h.Retention)
+	}
+	h.respondWithJSON(w, http.StatusOK, resp)
+}
+
+// UpdateRetention sets the webhook's own retention policy. Limits left out
+// fall back to the server default; -1 removes a limit.
+func (h *Handler) UpdateRetention(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	var policy database.RetentionPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if policy.MaxAgeSeconds < database.NoLimit || policy.MaxCount < database.NoLimit || policy.MaxBytes < database.NoLimit {
+		h.respondWithError(w, http.StatusBadRequest, "Retention limits must be positive, or -1 for no limit")
+		return
+	}
+	if policy == (database.RetentionPolicy{}) {
+		h.respondWithError(w, http.StatusBadRequest, "Set at least one of max_age_seconds, max_count or max_bytes")
+		return
+	}
+
+	if err := h.DB.UpdateRetention(r.Context(), webhookID, userID, &policy); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update retention policy")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, policy.Or(h.Retention))
+}
+
+// ResetRetention restores the server default retention policy.
+func (h *Handler) ResetRetention(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	if err := h.DB.UpdateRetention(r.Context(), webhookID, userID, nil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to reset retention policy")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Retention policy reset to default"})
+}
+
+// PinWebhookRequest exempts a captured request from the retention policy.
+func (h *Handler) PinWebhookRequest(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, true)
+}
+
+// UnpinWebhookRequest makes a captured request subject to the retention
+// policy again.
+func (h *Handler) UnpinWebhookRequest(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, false)
+}
+
+func (h *Handler) setPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+	requestID, ok := h.requestID(w, r, webhookID)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.SetRequestPinned(r.Context(), webhookID, requestID, pinned); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Request not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update webhook request")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]bool{"pinned": pinned})
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hookinator/internal/database"
+	"hookinator/internal/secrets"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// defaultSecretOverlap is how long a rotated-out secret keeps working.
+	defaultSecretOverlap = 24 * time.Hour
+	maxSecretOverlap     = 30 * 24 * time.Hour
+)
+
+type secretRequest struct {
+	Purpose string `json:"purpose"`
+	// Secret imports a secret issued elsewhere, typically a provider's
+	// signing secret; if empty one is generated.
+	Secret string `json:"secret"`
+	// OverlapSeconds is how long the secrets being replaced stay valid.
+	OverlapSeconds *int `json:"overlap_seconds"`
+}
+
+// requireSecrets writes an error response if no master key is configured.
+func (h *Handler) requireSecrets(w http.ResponseWriter) bool {
+	if h.Secrets == nil {
+		h.respondWithError(w, http.StatusServiceUnavailable, "Secret storage is not configured on this server")
+		return false
+	}
+	return true
+}
+
+// addSecret encrypts and stores a generated or imported secret, replacing
+// the active ones of the same purpose after overlap.
+func (h *Handler) addSecret(r *http.Request, webhookID, purpose, plaintext string, overlap time.Duration) (database.WebhookSecret, error) {
+	s := database.WebhookSecret{WebhookID: webhookID, Purpose: purpose}
+	if plaintext == "" {
+		generated, err := secrets.Generate()
+		if err != nil {
+			return database.WebhookSecret{}, err
+		}
+		plaintext = generated
+	} else {
+		// Imported secrets are already known to the user.
+		now := time.Now()
+		s.RevealedAt = &now
+	}
+	ciphertext, err := h.Secrets.Seal(webhookID, plaintext)
+	if err != nil {
+		return database.WebhookSecret{}, err
+	}
+	s.Ciphertext = ciphertext
+	s.Hint = database.SecretHint(plaintext)
+
+	created, err := h.DB.AddSecret(r.Context(), s, overlap)
+	if err != nil {
+		return database.WebhookSecret{}, err
+	}
+	if purpose == database.SecretVerification {
+		h.webhooks.invalidate(webhookID)
+	}
+	return created, nil
+}
+
+// decodeSecretRequest reads and validates a secret request, writing an error
+// response if it is invalid.
+func (h *Handler) decodeSecretRequest(w http.ResponseWriter, r *http.Request) (secretRequest, time.Duration, bool) {
+	var req secretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return secretRequest{}, 0, false
+	}
+	if req.Purpose != database.SecretVerification && req.Purpose != database.SecretSigning {
+		h.respondWithError(w, http.StatusBadRequest, "purpose must be verification or signing")
+		return secretRequest{}, 0, false
+	}
+	overlap, ok := h.secretOverlap(w, req.OverlapSeconds)
+	if !ok {
+		return secretRequest{}, 0, false
+	}
+	return req, overlap, true
+}
+
+// secretOverlap validates a requested rotation overlap, applying the default
+// if none was given.
+func (h *Handler) secretOverlap(w http.ResponseWriter, seconds *int) (time.Duration, bool) {
+	if seconds == nil {
+		return defaultSecretOverlap, true
+	}
+	overlap := time.Duration(*seconds) * time.Second
+	if overlap < 0 || overlap > maxSecretOverlap {
+		h.respondWithError(w, http.StatusBadRequest, "overlap_seconds must be between 0 and "+strconv.Itoa(int(maxSecretOverlap.Seconds())))
+		return 0, false
+	}
+	return overlap, true
+}
+
+// ListSecrets returns the webhook's secrets without their values.
+func (h *Handler) ListSecrets(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	list, err := h.DB.GetSecrets(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve secrets")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, list)
+}
+
+// CreateSecret generates or imports the first secret for a purpose. Use
+// RotateSecret to replace an active one.
+func (h *Handler) CreateSecret(w http.ResponseWriter, r *http.Request) {
+	if !h.requireSecrets(w) || !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+
+	req, _, ok := h.decodeSecretRequest(w, r)
+	if !ok {
+		return
+	}
+
+	active, err := h.DB.GetActiveSecrets(r.Context(), webhookID, req.Purpose)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve secrets")
+		return
+	}
+	if len(active) > 0 {
+		h.respondWithError(w, http.StatusConflict, "An active "+req.Purpose+" secret already exists; rotate it instead")
+		return
+	}
+
+	created, err := h.addSecret(r, webhookID, req.Purpose, req.Secret, 0)
+	if err != nil {
+		log.Printf("Failed to create secret for webhook %s: %v", webhookID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to create secret")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, created)
+}
+
+// RotateSecret generates or imports a new secret for a purpose. The secrets
+// it replaces keep working for the overlap, default 24 hours.
+func (h *Handler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	if !h.requireSecrets(w) || !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+
+	req, overlap, ok := h.decodeSecretRequest(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := h.addSecret(r, webhookID, req.Purpose, req.Secret, overlap)
+	if err != nil {
+		log.Printf("Failed to rotate secret for webhook %s: %v", webhookID, err)
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to rotate secret")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, created)
+}
+
+// RevealSecret returns the value of a generated secret. Each secret can be
+// revealed only once.
+func (h *Handler) RevealSecret(w http.ResponseWriter, r *http.Request) {
+	if !h.requireSecrets(w) || !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+
+	secretID, err := strconv.ParseInt(chi.URLParam(r, "secretId"), 10, 64)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid secret ID")
+		return
+	}
+
+	s, plaintext, err := h.DB.RevealSecret(r.Context(), webhookID, secretID, func(ciphertext []byte) (string, error) {
+		return h.Secrets.Open(webhookID, ciphertext)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.respondWithError(w, http.StatusNotFound, "Secret not found")
+		case errors.Is(err, database.ErrSecretRevealed):
+			h.respondWithError(w, http.StatusGone, "Secret has already been revealed; rotate it to get a new one")
+		default:
+			log.Printf("Failed to reveal secret %d of webhook %s: %v", secretID, webhookID, err)
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to reveal secret")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, struct {
+		database.WebhookSecret
+		Secret string `json:"secret"`
+	}{s, plaintext})
+}
+
+// RevokeSecret stops a secret from being accepted or used immediately.
+func (h *Handler) RevokeSecret(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+
+	secretID, err := strconv.ParseInt(chi.URLParam(r, "secretId"), 10, 64)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid secret ID")
+		return
+	}
+
+	if err := h.DB.RevokeSecret(r.Context(), webhookID, secretID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Secret not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to revoke secret")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Secret revoked successfully"})
+}
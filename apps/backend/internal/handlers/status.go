@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"hookinator/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// rejectDisabled is recorded for requests to disabled webhooks.
+	rejectDisabled = "disabled"
+
+	defaultRetryAfterSeconds = 300
+	maxRetryAfterSeconds     = 24 * 60 * 60
+)
+
+// GetWebhookStatus returns whether the webhook captures and forwards
+// requests.
+func (h *Handler) GetWebhookStatus(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	status, err := h.DB.GetWebhookStatus(r.Context(), webhookID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve webhook status")
+		}
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, status)
+}
+
+// UpdateWebhookStatus pauses, disables or reactivates the webhook. Resuming
+// forwarding releases the deliveries held while it was paused.
+func (h *Handler) UpdateWebhookStatus(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	var status database.WebhookStatus
+	if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	switch status.Status {
+	case database.WebhookActive, database.WebhookCaptureOnly, database.WebhookForwardingPaused, database.WebhookDisabled:
+	default:
+		h.respondWithError(w, http.StatusBadRequest, "status must be active, capture_only, forwarding_paused or disabled")
+		return
+	}
+	switch status.DisabledStatusCode {
+	case 0:
+		status.DisabledStatusCode = http.StatusServiceUnavailable
+	case http.StatusServiceUnavailable, http.StatusGone:
+	default:
+		h.respondWithError(w, http.StatusBadRequest, "disabled_status_code must be 503 or 410")
+		return
+	}
+	if status.RetryAfterSeconds == 0 {
+		status.RetryAfterSeconds = defaultRetryAfterSeconds
+	}
+	if status.RetryAfterSeconds < 1 || status.RetryAfterSeconds > maxRetryAfterSeconds {
+		h.respondWithError(w, http.StatusBadRequest, "retry_after_seconds must be between 1 and "+strconv.Itoa(maxRetryAfterSeconds))
+		return
+	}
+
+	if err := h.DB.UpdateWebhookStatus(r.Context(), webhookID, userID, status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update webhook status")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+	if status.Status != database.WebhookForwardingPaused {
+		h.Forwarder.Notify()
+	}
+
+	h.respondWithJSON(w, http.StatusOK, status)
+}
+
+// rejectDisabledWebhook answers a request to a disabled webhook with its
+// configured status and Retry-After.
+func (h *Handler) rejectDisabledWebhook(w http.ResponseWriter, r *http.Request, webhook *ingestConfig) {
+	w.Header().Set("Retry-After", strconv.Itoa(webhook.Status.RetryAfterSeconds))
+	h.rejectWebhook(w, r, webhook.ID, webhook.Status.DisabledStatusCode, rejectDisabled, "Webhook is disabled")
+}
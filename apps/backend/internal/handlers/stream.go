@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"hookinator/internal/database"
+	"hookinator/internal/utils"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// streamBacklogPage is how many missed requests are loaded at a time
+	// when a stream resumes.
+	streamBacklogPage = 500
+	// streamHeartbeat keeps idle streams from being closed by proxies.
+	streamHeartbeat = 25 * time.Second
+	// streamWriteTimeout bounds how long a slow client can hold up a write.
+	streamWriteTimeout = 10 * time.Second
+	// streamResumeOverlap is how long before the Last-Event-ID a resumed
+	// stream starts again. Requests are stamped when received but become
+	// visible when committed, so one stamped earlier can appear after the
+	// client saw a later one.
+	streamResumeOverlap = time.Minute
+	// streamResetCode closes a WebSocket whose Last-Event-ID no longer
+	// exists.
+	streamResetCode websocket.StatusCode = 4000
+)
+
+// streamConn sends requests to one stream client.
+type streamConn interface {
+	send(ctx context.Context, req database.WebhookRequest) error
+	heartbeat(ctx context.Context) error
+	// reset tells the client that the stream cannot resume where it left
+	// off, so it should reload the requests. It returns false if the stream
+	// ends.
+	reset(ctx context.Context) bool
+}
+
+// streamTicketTTL is how long a stream ticket can be used after it is
+// issued.
+const streamTicketTTL = 30 * time.Second
+
+// CreateStreamTicket issues a single-use ticket for opening a stream of the
+// webhook. Browsers cannot set headers on EventSource or WebSocket
+// connections, so they pass the ticket as ?ticket= instead of their token;
+// being short-lived and single-use, it is harmless in access logs.
+func (h *Handler) CreateStreamTicket(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	ticket, err := utils.GenerateID(32)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to generate stream ticket")
+		return
+	}
+	expiresAt := time.Now().Add(streamTicketTTL)
+	if err := h.DB.CreateStreamTicket(r.Context(), hashStreamTicket(ticket), userID, webhookID, expiresAt); err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to create stream ticket")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"ticket":     ticket,
+		"expires_at": expiresAt,
+	})
+}
+
+// StreamAuth authenticates a stream request by its ?ticket=, falling back to
+// the Authorization header for clients that can set it.
+func (h *Handler) StreamAuth(next http.Handler) http.Handler {
+	withToken := h.AuthMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ticket := r.URL.Query().Get("ticket")
+		if ticket == "" {
+			withToken.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := h.DB.RedeemStreamTicket(r.Context(), hashStreamTicket(ticket), chi.URLParam(r, "id"))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				h.respondWithError(w, http.StatusUnauthorized, "Invalid or expired stream ticket")
+			} else {
+				h.respondWithError(w, http.StatusInternalServerError, "Failed to verify stream ticket")
+			}
+			return
+		}
+		ctx := context.WithValue(r.Context(), userContextKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func hashStreamTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
+
+// streamOriginHosts returns the hosts of the allowed frontend origins, the
+// form the WebSocket origin check expects.
+func streamOriginHosts(origins []string) []string {
+	hosts := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		origin = strings.TrimSpace(origin)
+		if u, err := url.Parse(origin); err == nil && u.Host != "" {
+			origin = u.Host
+		}
+		if origin != "" {
+			hosts = append(hosts, origin)
+		}
+	}
+	return hosts
+}
+
+// StreamRequests pushes each request captured by a webhook to the client as
+// it arrives, over Server-Sent Events or, when the client asks to upgrade,
+// a WebSocket. Every request is sent as its JSON with its public ID as the
+// event ID; a client that reconnects with Last-Event-ID (or ?last_event_id=)
+// first receives the requests it missed, starting streamResumeOverlap
+// early, so it must skip IDs it has seen. If that request no longer exists,
+// an SSE stream sends a reset event and a WebSocket is closed with
+// streamResetCode.
+func (h *Handler) StreamRequests(w http.ResponseWriter, r *http.Request) {
+	if h.Stream == nil {
+		h.respondWithError(w, http.StatusServiceUnavailable, "Streaming is not available")
+		return
+	}
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+
+	// Subscribe before loading the backlog so that nothing saved in between
+	// is missed.
+	sub := h.Stream.Subscribe(webhookID)
+	if sub == nil {
+		h.respondWithError(w, http.StatusServiceUnavailable, "Server is shutting down")
+		return
+	}
+	defer sub.Close()
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	after, resume, err := h.resolveLastEventID(r.Context(), webhookID, lastEventID)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to resume stream")
+		return
+	}
+
+	// Streams outlive the server's ReadTimeout, which would otherwise end
+	// them.
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+
+	ctx := r.Context()
+	var conn streamConn
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		// Browsers may only connect from the frontend.
+		c, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: streamOriginHosts(h.AllowedOrigins)})
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+		// Nothing is read from the client, but reading handles pings and
+		// notices when it goes away.
+		ctx = c.CloseRead(ctx)
+		conn = webSocketConn{c}
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		rc := http.NewResponseController(w)
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		conn = sseConn{w: w, rc: rc}
+	}
+
+	if lastEventID != "" && !resume && !conn.reset(ctx) {
+		return
+	}
+
+	// A request saved while the backlog loads arrives twice.
+	sent := make(map[int64]bool)
+	for resume {
+		backlog, err := h.DB.GetRequestsAfter(ctx, webhookID, after, streamBacklogPage)
+		if err != nil {
+			return
+		}
+		for _, req := range backlog {
+			if err := conn.send(ctx, req); err != nil {
+				return
+			}
+			sent[req.ID] = true
+			after = database.RequestCursor{ReceivedAt: req.Timestamp, ID: req.ID}
+		}
+		resume = len(backlog) == streamBacklogPage
+	}
+
+	ticker := time.NewTicker(streamHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req, ok := <-sub.C:
+			if !ok {
+				// Closing makes the client reconnect and catch up.
+				if ws, isWS := conn.(webSocketConn); isWS {
+					ws.c.Close(websocket.StatusTryAgainLater, "reconnect with last_event_id")
+				}
+				return
+			}
+			if sent[req.ID] {
+				continue
+			}
+			if err := conn.send(ctx, req); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.heartbeat(ctx); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// resolveLastEventID returns where a stream resumes: streamResumeOverlap
+// before the request with the given event ID. resume is false if there is
+// no such request, in which case the stream starts with new requests.
+func (h *Handler) resolveLastEventID(ctx context.Context, webhookID, lastEventID string) (after database.RequestCursor, resume bool, err error) {
+	if lastEventID == "" {
+		return database.RequestCursor{}, false, nil
+	}
+	id, err := h.resolveRequestID(ctx, webhookID, lastEventID)
+	if err == nil {
+		after, err = h.DB.GetRequestCursor(ctx, webhookID, id)
+	}
+	if errors.Is(err, errInvalidRequestID) || errors.Is(err, sql.ErrNoRows) {
+		return database.RequestCursor{}, false, nil
+	}
+	if err != nil {
+		return database.RequestCursor{}, false, err
+	}
+	return database.RequestCursor{ReceivedAt: after.ReceivedAt.Add(-streamResumeOverlap)}, true, nil
+}
+
+type sseConn struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (c sseConn) send(ctx context.Context, req database.WebhookRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	id := req.PublicID
+	if id == "" {
+		id = strconv.FormatInt(req.ID, 10)
+	}
+	return c.write(fmt.Sprintf("id: %s\nevent: request\ndata: %s\n\n", id, data))
+}
+
+func (c sseConn) heartbeat(ctx context.Context) error {
+	return c.write(": ping\n\n")
+}
+
+func (c sseConn) reset(ctx context.Context) bool {
+	return c.write("event: reset\ndata: {\"reason\":\"last_event_id_not_found\"}\n\n") == nil
+}
+
+func (c sseConn) write(event string) error {
+	c.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if _, err := c.w.Write([]byte(event)); err != nil {
+		return err
+	}
+	return c.rc.Flush()
+}
+
+type webSocketConn struct {
+	c *websocket.Conn
+}
+
+func (c webSocketConn) send(ctx context.Context, req database.WebhookRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, streamWriteTimeout)
+	defer cancel()
+	return wsjson.Write(ctx, c.c, req)
+}
+
+func (c webSocketConn) heartbeat(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, streamWriteTimeout)
+	defer cancel()
+	return c.c.Ping(ctx)
+}
+
+func (c webSocketConn) reset(ctx context.Context) bool {
+	c.c.Close(streamResetCode, "last_event_id not found; reload requests and reconnect without it")
+	return false
+}
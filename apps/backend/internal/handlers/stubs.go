@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"hookinator/internal/database"
+	"hookinator/internal/stubs"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type stubRequest struct {
+	Name            string                  `json:"name"`
+	Priority        int                     `json:"priority"`
+	Enabled         *bool                   `json:"enabled"`
+	Match           database.StubMatch      `json:"match"`
+	Response        database.ResponseConfig `json:"response"`
+	Fault           *database.StubFault     `json:"fault"`
+	Scenario        string                  `json:"scenario"`
+	RequiredState   string                  `json:"required_state"`
+	NewState        string                  `json:"new_state"`
+	TransitionAfter int                     `json:"transition_after"`
+}
+
+// toStub validates the request and fills in defaults.
+func (req stubRequest) toStub(webhookID string) (database.Stub, error) {
+	if req.Response.Status == 0 {
+		req.Response.Status = http.StatusOK
+	}
+	if req.TransitionAfter < 0 {
+		req.TransitionAfter = 0
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	stub := database.Stub{
+		WebhookID:       webhookID,
+		Name:            req.Name,
+		Priority:        req.Priority,
+		Enabled:         enabled,
+		Match:           req.Match,
+		Response:        req.Response,
+		Fault:           req.Fault,
+		Scenario:        req.Scenario,
+		RequiredState:   req.RequiredState,
+		NewState:        req.NewState,
+		TransitionAfter: req.TransitionAfter,
+	}
+	if _, err := stubs.Compile(stub); err != nil {
+		return database.Stub{}, err
+	}
+	return stub, nil
+}
+
+func (h *Handler) ListStubs(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	list, err := h.DB.GetStubs(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve stubs")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, list)
+}
+
+func (h *Handler) CreateStub(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+
+	var req stubRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	stub, err := req.toStub(webhookID)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	created, err := h.DB.CreateStub(r.Context(), stub)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to create stub")
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) UpdateStub(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+
+	stubID, err := strconv.ParseInt(chi.URLParam(r, "stubId"), 10, 64)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid stub ID")
+		return
+	}
+
+	var req stubRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	stub, err := req.toStub(webhookID)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	stub.ID = stubID
+
+	updated, err := h.DB.UpdateStub(r.Context(), stub)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Stub not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update stub")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handler) DeleteStub(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+	webhookID := chi.URLParam(r, "id")
+
+	stubID, err := strconv.ParseInt(chi.URLParam(r, "stubId"), 10, 64)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid stub ID")
+		return
+	}
+
+	if err := h.DB.DeleteStub(r.Context(), webhookID, stubID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Stub not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to delete stub")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Stub deleted successfully"})
+}
+
+// ListScenarios returns the current state of each scenario that has been
+// entered; scenarios not listed are in their initial state.
+func (h *Handler) ListScenarios(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	scenarios, err := h.DB.GetScenarios(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve scenarios")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, scenarios)
+}
+
+// ResetScenarios returns every scenario to its initial state and restarts
+// error bursts, typically between integration test runs.
+func (h *Handler) ResetScenarios(w http.ResponseWriter, r *http.Request) {
+	if !h.ownsWebhook(w, r) {
+		return
+	}
+
+	if err := h.DB.ResetScenarios(r.Context(), chi.URLParam(r, "id")); err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to reset scenarios")
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Scenarios reset"})
+}
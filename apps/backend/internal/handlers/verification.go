@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"hookinator/internal/database"
+	"hookinator/internal/verify"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// verificationRequest turns on signature checks. Secret optionally imports
+// the provider's secret, rotating out the current one after the overlap.
+type verificationRequest struct {
+	database.VerificationConfig
+	Secret         string `json:"secret"`
+	OverlapSeconds *int   `json:"overlap_seconds"`
+}
+
+// verificationResponse describes a webhook's signature checks without
+// exposing its secrets.
+type verificationResponse struct {
+	SourceType    string `json:"source_type"`
+	Enabled       bool   `json:"enabled"`
+	ActiveSecrets int    `json:"active_secrets"`
+	*database.VerificationConfig
+}
+
+// verificationStatus builds the response for a webhook, writing an error
+// response and returning false on failure.
+func (h *Handler) verificationStatus(w http.ResponseWriter, r *http.Request) (verificationResponse, bool) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	cfg, sourceType, err := h.DB.GetVerificationConfig(r.Context(), webhookID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve verification config")
+		}
+		return verificationResponse{}, false
+	}
+	active, err := h.DB.GetActiveSecrets(r.Context(), webhookID, database.SecretVerification)
+	if err != nil {
+		h.respondWithError(w, http.StatusInternalServerError, "Failed to retrieve verification secrets")
+		return verificationResponse{}, false
+	}
+	return verificationResponse{
+		SourceType:         sourceType,
+		Enabled:            cfg != nil,
+		ActiveSecrets:      len(active),
+		VerificationConfig: cfg,
+	}, true
+}
+
+// GetVerification returns how the signatures of the webhook's incoming
+// requests are checked.
+func (h *Handler) GetVerification(w http.ResponseWriter, r *http.Request) {
+	status, ok := h.verificationStatus(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithJSON(w, http.StatusOK, status)
+}
+
+// UpdateVerification turns on signature checks using the scheme of the
+// webhook's source type and the webhook's verification secrets.
+func (h *Handler) UpdateVerification(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	var req verificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.ToleranceSeconds < 0 {
+		h.respondWithError(w, http.StatusBadRequest, "tolerance_seconds cannot be negative")
+		return
+	}
+	overlap, ok := h.secretOverlap(w, req.OverlapSeconds)
+	if !ok || !h.requireSecrets(w) {
+		return
+	}
+
+	status, ok := h.verificationStatus(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := verify.Lookup(status.SourceType); !ok {
+		h.respondWithError(w, http.StatusBadRequest, "Source type "+status.SourceType+" has no signature scheme; supported: "+strings.Join(verify.SourceTypes(), ", "))
+		return
+	}
+	if req.Secret == "" && status.ActiveSecrets == 0 {
+		h.respondWithError(w, http.StatusBadRequest, "secret is required when the webhook has no verification secret")
+		return
+	}
+
+	if req.Secret != "" {
+		if _, err := h.addSecret(r, webhookID, database.SecretVerification, req.Secret, overlap); err != nil {
+			log.Printf("Failed to store verification secret for webhook %s: %v", webhookID, err)
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to store verification secret")
+			return
+		}
+	}
+
+	if err := h.DB.UpdateVerificationConfig(r.Context(), webhookID, userID, &req.VerificationConfig); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to update verification config")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	if status, ok = h.verificationStatus(w, r); ok {
+		h.respondWithJSON(w, http.StatusOK, status)
+	}
+}
+
+// DisableVerification stops checking signatures. The verification secrets
+// are kept.
+func (h *Handler) DisableVerification(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userContextKey).(string)
+	webhookID := chi.URLParam(r, "id")
+
+	if err := h.DB.UpdateVerificationConfig(r.Context(), webhookID, userID, nil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondWithError(w, http.StatusNotFound, "Webhook not found")
+		} else {
+			h.respondWithError(w, http.StatusInternalServerError, "Failed to disable verification")
+		}
+		return
+	}
+	h.webhooks.invalidate(webhookID)
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Signature verification disabled"})
+}
@@ -0,0 +1,216 @@
+// Package ingest buffers captured requests in memory and saves them in
+// batches, so senders are acknowledged without waiting for the database.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hookinator/internal/database"
+)
+
+const (
+	// saveTimeout bounds one attempt to save a batch.
+	saveTimeout = 30 * time.Second
+	// maxSaveAttempts is how often a batch is tried before its requests are
+	// saved one at a time.
+	maxSaveAttempts = 5
+	// requestOverhead approximates the memory of a buffered request beyond
+	// its body and query.
+	requestOverhead = 1 << 10
+)
+
+var (
+	// ErrFull is returned when the buffer holds as many requests or bytes as
+	// it may; senders should retry later.
+	ErrFull = errors.New("ingestion buffer is full")
+	// ErrClosed is returned once the server is shutting down.
+	ErrClosed = errors.New("ingestion buffer is closed")
+)
+
+// Config controls the buffer.
+type Config struct {
+	// Size is how many requests may wait to be saved.
+	Size int
+	// MaxBytes caps the memory of waiting requests, approximately.
+	MaxBytes int64
+	// BatchSize is the most requests saved in one transaction.
+	BatchSize int
+	// FlushInterval is how long a partial batch waits for more requests.
+	FlushInterval time.Duration
+}
+
+// Buffer accepts captured requests and saves them in the background.
+type Buffer struct {
+	DB     *database.DB
+	Config Config
+	// notify is called after requests that need delivering were saved.
+	notify func()
+
+	mu      sync.RWMutex
+	closed  bool
+	pending chan database.PendingRequest
+	bytes   atomic.Int64
+	done    chan struct{}
+}
+
+// New creates a Buffer. Run must be started for requests to be saved.
+func New(db *database.DB, cfg Config, notify func()) *Buffer {
+	return &Buffer{
+		DB:      db,
+		Config:  cfg,
+		notify:  notify,
+		pending: make(chan database.PendingRequest, cfg.Size),
+		done:    make(chan struct{}),
+	}
+}
+
+// Submit queues a request to be saved. It never blocks: when the buffer is
+// full it returns ErrFull.
+func (b *Buffer) Submit(webhookID string, req database.WebhookRequest, opts database.SaveOptions) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrClosed
+	}
+
+	size := requestSize(req)
+	// A single request larger than the budget is still accepted into an
+	// empty buffer, or it could never be captured.
+	if used := b.bytes.Add(size); used > b.Config.MaxBytes && used != size {
+		b.bytes.Add(-size)
+		return ErrFull
+	}
+	select {
+	case b.pending <- database.PendingRequest{WebhookID: webhookID, Request: req, Options: opts}:
+		return nil
+	default:
+		b.bytes.Add(-size)
+		return ErrFull
+	}
+}
+
+// Close stops accepting requests and waits until every buffered request
+// has been saved. Call it once the HTTP server has stopped.
+func (b *Buffer) Close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.pending)
+	}
+	b.mu.Unlock()
+	<-b.done
+}
+
+// Run saves buffered requests in batches until Close is called and the
+// buffer is drained.
+func (b *Buffer) Run() {
+	defer close(b.done)
+
+	batch := make([]database.PendingRequest, 0, b.Config.BatchSize)
+	timer := time.NewTimer(b.Config.FlushInterval)
+	timer.Stop()
+	for {
+		// Wait for the first request of a batch, then give the rest of it
+		// FlushInterval to arrive.
+		p, ok := <-b.pending
+		if !ok {
+			return
+		}
+		batch = append(batch, p)
+		timer.Reset(b.Config.FlushInterval)
+	fill:
+		for len(batch) < b.Config.BatchSize {
+			select {
+			case p, ok := <-b.pending:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, p)
+			case <-timer.C:
+				break fill
+			}
+		}
+		timer.Stop()
+
+		b.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush saves a batch, retrying with backoff while the database is
+// unavailable; meanwhile the buffer fills up and senders are turned away.
+// A batch that keeps failing is saved one request at a time so one bad
+// request cannot lose the rest.
+func (b *Buffer) flush(batch []database.PendingRequest) {
+	defer func() {
+		var size int64
+		for _, p := range batch {
+			size += requestSize(p.Request)
+		}
+		b.bytes.Add(-size)
+	}()
+
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := b.save(func(ctx context.Context) error { return b.DB.SaveRequests(ctx, batch) })
+		if err == nil {
+			b.saved(batch)
+			return
+		}
+		if errors.Is(err, database.ErrWebhookGone) || attempt == maxSaveAttempts {
+			log.Printf("Failed to save batch of %d requests, saving them one at a time: %v", len(batch), err)
+			break
+		}
+		log.Printf("Failed to save batch of %d requests (attempt %d): %v", len(batch), attempt, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	for i := range batch {
+		p := &batch[i]
+		p.Request.ID, p.Request.DuplicateOf = 0, nil
+		err := b.save(func(ctx context.Context) error {
+			return b.DB.SaveRequest(ctx, p.WebhookID, &p.Request, p.Options)
+		})
+		switch {
+		case errors.Is(err, database.ErrWebhookGone):
+			log.Printf("Dropping request for webhook %s: webhook has been deleted", p.WebhookID)
+		case err != nil:
+			log.Printf("Dropping request for webhook %s received at %s: %v", p.WebhookID, p.Request.Timestamp.Format(time.RFC3339Nano), err)
+		default:
+			b.saved(batch[i : i+1])
+		}
+	}
+}
+
+func (b *Buffer) save(fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// saved logs duplicates and wakes the forwarder if anything was queued for
+// delivery.
+func (b *Buffer) saved(batch []database.PendingRequest) {
+	deliver := false
+	for _, p := range batch {
+		switch {
+		case p.Request.DuplicateOf != nil:
+			log.Printf("Request %d for webhook %s duplicates request %d, not forwarding", p.Request.ID, p.WebhookID, *p.Request.DuplicateOf)
+		case !p.Options.SkipDelivery:
+			deliver = true
+		}
+	}
+	if deliver && b.notify != nil {
+		b.notify()
+	}
+}
+
+func requestSize(req database.WebhookRequest) int64 {
+	return int64(len(req.Body)+len(req.Query)) + requestOverhead
+}
@@ -0,0 +1,173 @@
+// Package ingestauth authenticates senders of incoming webhook requests with
+// per-webhook credentials.
+package ingestauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"hookinator/internal/database"
+	"hookinator/internal/utils"
+)
+
+// Modes a webhook can require.
+const (
+	ModeBasic  = "basic"
+	ModeBearer = "bearer"
+	ModeQuery  = "query"
+	ModeMTLS   = "mtls"
+)
+
+// Reasons a request fails authentication.
+const (
+	ReasonMissing = "auth_missing"
+	ReasonInvalid = "auth_invalid"
+)
+
+// DefaultQueryParam carries the secret in query mode unless configured.
+const DefaultQueryParam = "token"
+
+// MinSecretLength is the shortest password or token a user may choose.
+// Secrets are stored as unsalted SHA-256 digests, which only holds up for
+// values too long to brute-force.
+const MinSecretLength = 16
+
+// generatedSecretLength gives generated secrets about 190 bits of entropy.
+const generatedSecretLength = 32
+
+// Generate returns a new random password or token.
+func Generate() (string, error) {
+	secret, err := utils.GenerateID(generatedSecretLength)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return secret, nil
+}
+
+// Hash returns the digest a secret is stored as.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER certificate, in the
+// lowercase hex form that configs use.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeFingerprint accepts fingerprints with or without colons and in
+// either case.
+func NormalizeFingerprint(s string) (string, error) {
+	f := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), ":", ""))
+	if b, err := hex.DecodeString(f); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 fingerprint %q", s)
+	}
+	return f, nil
+}
+
+// Validate checks that a config is complete for its mode.
+func Validate(cfg database.IngestAuthConfig) error {
+	switch cfg.Mode {
+	case ModeBasic:
+		if cfg.Username == "" || cfg.SecretHash == "" {
+			return fmt.Errorf("basic auth needs a username and password")
+		}
+	case ModeBearer, ModeQuery:
+		if cfg.SecretHash == "" {
+			return fmt.Errorf("%s auth needs a token", cfg.Mode)
+		}
+	case ModeMTLS:
+		if len(cfg.Fingerprints) == 0 {
+			return fmt.Errorf("mtls auth needs at least one certificate fingerprint")
+		}
+		for _, f := range cfg.Fingerprints {
+			if _, err := NormalizeFingerprint(f); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("mode must be basic, bearer, query or mtls")
+	}
+	return nil
+}
+
+// Check authenticates a request, returning "" if it passes or the reason it
+// fails.
+func Check(cfg database.IngestAuthConfig, r *http.Request) string {
+	switch cfg.Mode {
+	case ModeBasic:
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			return ReasonMissing
+		}
+		userOK := subtle.ConstantTimeCompare([]byte(username), []byte(cfg.Username)) == 1
+		if !hashMatches(password, cfg.SecretHash) || !userOK {
+			return ReasonInvalid
+		}
+	case ModeBearer:
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return ReasonMissing
+		}
+		if !hashMatches(token, cfg.SecretHash) {
+			return ReasonInvalid
+		}
+	case ModeQuery:
+		token := r.URL.Query().Get(queryParam(cfg))
+		if token == "" {
+			return ReasonMissing
+		}
+		if !hashMatches(token, cfg.SecretHash) {
+			return ReasonInvalid
+		}
+	case ModeMTLS:
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return ReasonMissing
+		}
+		got := Fingerprint(r.TLS.PeerCertificates[0].Raw)
+		for _, f := range cfg.Fingerprints {
+			if want, err := NormalizeFingerprint(f); err == nil && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1 {
+				return ""
+			}
+		}
+		return ReasonInvalid
+	default:
+		return ReasonInvalid
+	}
+	return ""
+}
+
+// StripCredentials removes the credentials a mode carries in the request
+// from what is captured and forwarded, so they never reach destinations.
+func StripCredentials(cfg database.IngestAuthConfig, header http.Header, rawQuery string) (http.Header, string) {
+	switch cfg.Mode {
+	case ModeBasic, ModeBearer:
+		header = header.Clone()
+		header.Del("Authorization")
+	case ModeQuery:
+		query, err := url.ParseQuery(rawQuery)
+		if err == nil {
+			query.Del(queryParam(cfg))
+			rawQuery = query.Encode()
+		}
+	}
+	return header, rawQuery
+}
+
+func queryParam(cfg database.IngestAuthConfig) string {
+	if cfg.QueryParam != "" {
+		return cfg.QueryParam
+	}
+	return DefaultQueryParam
+}
+
+func hashMatches(secret, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(Hash(secret)), []byte(hash)) == 1
+}
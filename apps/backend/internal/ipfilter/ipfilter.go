@@ -0,0 +1,98 @@
+// Package ipfilter decides which client addresses may send requests to a
+// webhook.
+package ipfilter
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"hookinator/internal/database"
+	"hookinator/internal/utils"
+)
+
+// Reasons a request is blocked.
+const (
+	ReasonDenied     = "ip_denied"
+	ReasonNotAllowed = "ip_not_allowed"
+)
+
+// Filter is a compiled IP filter.
+type Filter struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// Presets lists the bundled provider presets.
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Compile validates a filter config and expands its presets into the
+// allowlist.
+func Compile(cfg database.IPFilterConfig) (*Filter, error) {
+	f := &Filter{}
+	for _, s := range cfg.Allow {
+		prefix, err := utils.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("allow: %w", err)
+		}
+		f.allow = append(f.allow, prefix)
+	}
+	for _, s := range cfg.Deny {
+		prefix, err := utils.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("deny: %w", err)
+		}
+		f.deny = append(f.deny, prefix)
+	}
+	for _, name := range cfg.Presets {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if reason, ok := unavailable[key]; ok {
+			return nil, fmt.Errorf("no %s preset: %s", name, reason)
+		}
+		ranges, ok := presets[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown preset %q; available: %s", name, strings.Join(Presets(), ", "))
+		}
+		for _, s := range ranges {
+			prefix, err := utils.ParseCIDR(s)
+			if err != nil {
+				return nil, fmt.Errorf("preset %s: %w", key, err)
+			}
+			f.allow = append(f.allow, prefix)
+		}
+	}
+	return f, nil
+}
+
+// DenyAll returns a filter that blocks every address.
+func DenyAll() *Filter {
+	return &Filter{deny: []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0")}}
+}
+
+// Check returns the reason ip is blocked, or "" if it may send requests.
+// The denylist wins over the allowlist; an empty allowlist allows every
+// address not denied.
+func (f *Filter) Check(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		if len(f.allow) > 0 || len(f.deny) > 0 {
+			return ReasonNotAllowed
+		}
+		return ""
+	}
+	if utils.ContainsIP(f.deny, addr) {
+		return ReasonDenied
+	}
+	if len(f.allow) > 0 && !utils.ContainsIP(f.allow, addr) {
+		return ReasonNotAllowed
+	}
+	return ""
+}
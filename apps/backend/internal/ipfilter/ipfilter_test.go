@@ -0,0 +1,90 @@
+package ipfilter
+
+import (
+	"testing"
+
+	"hookinator/internal/database"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  database.IPFilterConfig
+		ip   string
+		want string
+	}{
+		{"empty filter", database.IPFilterConfig{}, "203.0.113.7", ""},
+		{"empty filter with unparsable address", database.IPFilterConfig{}, "unknown", ""},
+		{"allowed", database.IPFilterConfig{Allow: []string{"203.0.113.0/24"}}, "203.0.113.7", ""},
+		{"allowed single address", database.IPFilterConfig{Allow: []string{"203.0.113.7"}}, "203.0.113.7", ""},
+		{"not allowed", database.IPFilterConfig{Allow: []string{"203.0.113.0/24"}}, "198.51.100.1", ReasonNotAllowed},
+		{"denied", database.IPFilterConfig{Deny: []string{"198.51.100.0/24"}}, "198.51.100.1", ReasonDenied},
+		{"not denied", database.IPFilterConfig{Deny: []string{"198.51.100.0/24"}}, "203.0.113.7", ""},
+		{
+			name: "deny wins over allow",
+			cfg:  database.IPFilterConfig{Allow: []string{"203.0.113.0/24"}, Deny: []string{"203.0.113.7"}},
+			ip:   "203.0.113.7",
+			want: ReasonDenied,
+		},
+		{
+			name: "rest of allowed range",
+			cfg:  database.IPFilterConfig{Allow: []string{"203.0.113.0/24"}, Deny: []string{"203.0.113.7"}},
+			ip:   "203.0.113.8",
+		},
+		{"IPv4-mapped address allowed", database.IPFilterConfig{Allow: []string{"203.0.113.0/24"}}, "::ffff:203.0.113.7", ""},
+		{"IPv4-mapped address denied", database.IPFilterConfig{Deny: []string{"203.0.113.0/24"}}, "::ffff:203.0.113.7", ReasonDenied},
+		{"IPv4-mapped single address", database.IPFilterConfig{Allow: []string{"::ffff:203.0.113.7"}}, "203.0.113.7", ""},
+		{"IPv4-mapped range", database.IPFilterConfig{Allow: []string{"::ffff:203.0.113.0/120"}}, "203.0.113.7", ""},
+		{"IPv4-mapped range denied", database.IPFilterConfig{Deny: []string{"::ffff:203.0.113.0/120"}}, "::ffff:203.0.113.7", ReasonDenied},
+		{"IPv6", database.IPFilterConfig{Allow: []string{"2001:db8::/32"}}, "2001:db8::1", ""},
+		{"IPv6 not allowed", database.IPFilterConfig{Allow: []string{"2001:db8::/32"}}, "2001:db9::1", ReasonNotAllowed},
+		{"unparsable address with a filter", database.IPFilterConfig{Deny: []string{"198.51.100.0/24"}}, "unknown", ReasonNotAllowed},
+	}
+	for _, tt := range tests {
+		f, err := Compile(tt.cfg)
+		if err != nil {
+			t.Errorf("%s: Compile: %v", tt.name, err)
+			continue
+		}
+		if got := f.Check(tt.ip); got != tt.want {
+			t.Errorf("%s: Check(%s) = %q, want %q", tt.name, tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  database.IPFilterConfig
+	}{
+		{"bad allow", database.IPFilterConfig{Allow: []string{"10.0.0.0/40"}}},
+		{"bad deny", database.IPFilterConfig{Deny: []string{"nope"}}},
+		{"unknown preset", database.IPFilterConfig{Presets: []string{"no-such-provider"}}},
+	}
+	for _, tt := range tests {
+		if _, err := Compile(tt.cfg); err == nil {
+			t.Errorf("%s: Compile succeeded, want error", tt.name)
+		}
+	}
+}
+
+func TestPresets(t *testing.T) {
+	for _, name := range Presets() {
+		f, err := Compile(database.IPFilterConfig{Presets: []string{name}})
+		if err != nil {
+			t.Errorf("preset %s: %v", name, err)
+			continue
+		}
+		if len(f.allow) == 0 {
+			t.Errorf("preset %s allows every address", name)
+		}
+	}
+}
+
+func TestDenyAll(t *testing.T) {
+	for _, ip := range []string{"203.0.113.7", "2001:db8::1", "::ffff:10.0.0.1"} {
+		if got := DenyAll().Check(ip); got != ReasonDenied {
+			t.Errorf("DenyAll().Check(%s) = %q, want %q", ip, got, ReasonDenied)
+		}
+	}
+}
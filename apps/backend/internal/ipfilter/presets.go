@@ -0,0 +1,37 @@
+package ipfilter
+
+// presets are bundled copies of the source ranges providers publish for
+// their webhooks, so filters work without fetching them at runtime. Update
+// them when the providers change their lists.
+var presets = map[string][]string{
+	// The "hooks" ranges from https://api.github.com/meta.
+	"github": {
+		"192.30.252.0/22",
+		"185.199.108.0/22",
+		"140.82.112.0/20",
+		"143.55.64.0/20",
+		"2a0a:a440::/29",
+		"2606:50c0::/32",
+	},
+	// https://stripe.com/files/ips/ips_webhooks.txt
+	"stripe": {
+		"3.18.12.63",
+		"3.130.192.231",
+		"13.235.14.237",
+		"13.235.122.149",
+		"18.211.135.69",
+		"35.154.171.200",
+		"52.15.183.38",
+		"54.88.130.119",
+		"54.88.130.237",
+		"54.187.174.169",
+		"54.187.205.235",
+		"54.187.216.72",
+	},
+}
+
+// unavailable are providers that are asked for as presets but publish no
+// stable source ranges, with the reason given to the user.
+var unavailable = map[string]string{
+	"slack": "Slack does not publish stable source IP ranges for event and interaction requests; use signature verification instead",
+}
@@ -0,0 +1,200 @@
+// Package janitor periodically removes data that has outlived its use.
+package janitor
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"hookinator/internal/database"
+)
+
+// expiredBatch is how many expired webhooks are looked up at a time.
+const expiredBatch = 100
+
+// pruneBatch is how many requests are pruned per statement, so one sweep
+// never holds locks on a huge number of rows.
+const pruneBatch = 500
+
+// Refused requests are logged for their webhook's owner, but anyone who
+// knows a webhook ID can add to the log, so only the most recent ones are
+// kept.
+const (
+	blockedMaxAge     = 30 * 24 * time.Hour
+	blockedPerWebhook = 1000
+)
+
+// Janitor deletes expired webhooks, prunes captured requests beyond their
+// retention policy and trims the log of refused requests in the background.
+type Janitor struct {
+	DB       *database.DB
+	Interval time.Duration
+	// Retention is the default policy; a webhook's own policy overrides it
+	// limit by limit.
+	Retention database.RetentionPolicy
+}
+
+// New creates a Janitor that sweeps every interval.
+func New(db *database.DB, interval time.Duration, retention database.RetentionPolicy) *Janitor {
+	return &Janitor{DB: db, Interval: interval, Retention: retention}
+}
+
+// Run sweeps until ctx is cancelled.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+	for {
+		j.sweep(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *Janitor) sweep(ctx context.Context) {
+	total := 0
+	for ctx.Err() == nil {
+		deleted, found, err := j.deleteExpired(ctx)
+		if err != nil {
+			log.Printf("Janitor: %v", err)
+		}
+		total += deleted
+		if found < expiredBatch || deleted == 0 {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Janitor: deleted %d expired webhooks", total)
+	}
+
+	j.prune(ctx)
+	j.pruneBlocked(ctx)
+}
+
+// deleteExpired deletes up to expiredBatch expired webhooks, draining their
+// requests in batches first. It returns how many were deleted and found.
+func (j *Janitor) deleteExpired(ctx context.Context) (int, int, error) {
+	ids, err := j.DB.GetExpiredWebhooks(ctx, expiredBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+	deleted := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		ok, err := j.deleteWebhook(ctx, id)
+		if err != nil {
+			log.Printf("Janitor: %v", err)
+			continue
+		}
+		if ok {
+			deleted++
+		}
+	}
+	return deleted, len(ids), nil
+}
+
+// deleteWebhook drains and deletes one expired webhook.
+func (j *Janitor) deleteWebhook(ctx context.Context, webhookID string) (bool, error) {
+	for {
+		drained, err := j.DB.DrainExpiredWebhook(ctx, webhookID, pruneBatch)
+		if err != nil {
+			return false, err
+		}
+		if drained < pruneBatch {
+			break
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+	}
+	return j.DB.DeleteExpiredWebhook(ctx, webhookID)
+}
+
+// pruneBlocked trims the log of refused requests.
+func (j *Janitor) pruneBlocked(ctx context.Context) {
+	olderThan := time.Now().Add(-blockedMaxAge)
+	total := 0
+	for ctx.Err() == nil {
+		deleted, err := j.DB.PruneBlockedRequests(ctx, olderThan, blockedPerWebhook, pruneBatch)
+		if err != nil {
+			log.Printf("Janitor: %v", err)
+			break
+		}
+		total += deleted
+		if deleted < pruneBatch {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Janitor: pruned %d blocked requests", total)
+	}
+}
+
+// prune applies the retention policies.
+func (j *Janitor) prune(ctx context.Context) {
+	policies, err := j.DB.GetRetentionPolicies(ctx)
+	if err != nil {
+		log.Printf("Janitor: %v", err)
+		return
+	}
+	webhookIDs := make([]string, 0, len(policies))
+	if j.Retention.Unlimited() {
+		for id := range policies {
+			webhookIDs = append(webhookIDs, id)
+		}
+	} else if webhookIDs, err = j.DB.GetWebhooksWithRequests(ctx); err != nil {
+		log.Printf("Janitor: %v", err)
+		return
+	}
+
+	total := 0
+	for _, id := range webhookIDs {
+		if ctx.Err() != nil {
+			break
+		}
+		pruned, err := j.pruneWebhook(ctx, id, policies[id].Or(j.Retention))
+		if err != nil {
+			log.Printf("Janitor: %v", err)
+		}
+		total += pruned
+	}
+	if total > 0 {
+		log.Printf("Janitor: pruned %d requests", total)
+	}
+}
+
+func (j *Janitor) pruneWebhook(ctx context.Context, webhookID string, policy database.RetentionPolicy) (int, error) {
+	if policy.Unlimited() {
+		return 0, nil
+	}
+	var olderThan time.Time
+	if policy.MaxAgeSeconds > 0 {
+		olderThan = time.Now().Add(-time.Duration(policy.MaxAgeSeconds) * time.Second)
+	}
+	// Requests captured after the boundary is found are newer than it, so
+	// they are left for the next sweep.
+	boundary, err := j.DB.RetentionBoundary(ctx, webhookID, policy)
+	if err != nil {
+		return 0, err
+	}
+	if olderThan.IsZero() && boundary == nil {
+		return 0, nil
+	}
+
+	total := 0
+	for ctx.Err() == nil {
+		deleted, err := j.DB.PruneRequests(ctx, webhookID, olderThan, boundary, pruneBatch)
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+		if deleted < pruneBatch {
+			break
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,112 @@
+// Package jsonpath evaluates the simple JSON paths used in webhook rules,
+// such as "$.data.object.id", "event.type" or "$.items[0].name", against
+// documents decoded with encoding/json.
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type step struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// Path is a parsed JSON path.
+type Path struct {
+	raw   string
+	steps []step
+}
+
+// Parse parses a dotted path with optional [n] array indexes and ["key"]
+// segments for keys containing dots. A leading "$" or "$." is optional.
+func Parse(raw string) (Path, error) {
+	s := strings.TrimSpace(raw)
+	s = strings.TrimPrefix(s, "$")
+	s = strings.TrimPrefix(s, ".")
+	p := Path{raw: raw}
+	for s != "" {
+		switch {
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return Path{}, fmt.Errorf("invalid JSON path %q: unclosed [", raw)
+			}
+			inner := s[1:end]
+			if unquoted, err := strconv.Unquote(inner); err == nil {
+				p.steps = append(p.steps, step{key: unquoted})
+			} else if n, err := strconv.Atoi(inner); err == nil && n >= 0 {
+				p.steps = append(p.steps, step{index: n, isIdx: true})
+			} else {
+				return Path{}, fmt.Errorf("invalid JSON path %q: bad segment [%s]", raw, inner)
+			}
+			s = strings.TrimPrefix(s[end+1:], ".")
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return Path{}, fmt.Errorf("invalid JSON path %q: empty segment", raw)
+			}
+			p.steps = append(p.steps, step{key: s[:end]})
+			s = s[end:]
+			s = strings.TrimPrefix(s, ".")
+		}
+	}
+	if len(p.steps) == 0 {
+		return Path{}, fmt.Errorf("invalid JSON path %q: no segments", raw)
+	}
+	return p, nil
+}
+
+// String returns the path as it was written.
+func (p Path) String() string {
+	return p.raw
+}
+
+// Lookup returns the value at the path in doc, which must come from
+// json.Unmarshal into an interface{}.
+func (p Path) Lookup(doc interface{}) (interface{}, bool) {
+	cur := doc
+	for _, st := range p.steps {
+		if st.isIdx {
+			arr, ok := cur.([]interface{})
+			if !ok || st.index >= len(arr) {
+				return nil, false
+			}
+			cur = arr[st.index]
+			continue
+		}
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = obj[st.key]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// LookupString returns the value at the path rendered as a string: strings
+// as-is, other scalars and containers as JSON. Missing values and JSON null
+// report false.
+func (p Path) LookupString(doc interface{}) (string, bool) {
+	v, ok := p.Lookup(doc)
+	if !ok || v == nil {
+		return "", false
+	}
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
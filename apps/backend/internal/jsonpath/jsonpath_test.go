@@ -0,0 +1,72 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, raw := range []string{"", "$", "$.", "a..b", "a[", "a[-1]", "a[x]"} {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestLookupString(t *testing.T) {
+	var doc interface{}
+	body := `{
+		"type": "invoice.paid",
+		"data": {"object": {"id": "in_123", "amount": 4200, "paid": true, "refund": null}},
+		"items": [{"name": "first"}, {"name": "second"}],
+		"a.b": {"c": "dotted"},
+		"nested": {"list": [1, 2]}
+	}`
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"$.type", "invoice.paid", true},
+		{"type", "invoice.paid", true},
+		{"$type", "invoice.paid", true},
+		{"$.data.object.id", "in_123", true},
+		{"data.object.amount", "4200", true},
+		{"data.object.paid", "true", true},
+		{"$.items[1].name", "second", true},
+		{`$["a.b"].c`, "dotted", true},
+		{"nested.list", "[1,2]", true},
+		{"data.object", `{"amount":4200,"id":"in_123","paid":true,"refund":null}`, true},
+		{"data.object.refund", "", false},
+		{"data.object.missing", "", false},
+		{"$.items[2].name", "", false},
+		{"$.type.inner", "", false},
+		{"$.items.name", "", false},
+		{"$.data[0]", "", false},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.path)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.path, err)
+			continue
+		}
+		got, ok := p.LookupString(doc)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("LookupString(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	p, err := Parse(" $.data.id ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.String(); got != " $.data.id " {
+		t.Errorf("String() = %q, want the path as written", got)
+	}
+}
@@ -0,0 +1,139 @@
+// Package outbound decides which addresses the server may connect to on
+// behalf of users. Forward URLs, destinations and replay targets are chosen
+// by users and their responses are shown back to them, so without a policy
+// they could read cloud metadata or services on the internal network.
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"hookinator/internal/utils"
+)
+
+// maxRedirects matches the limit of the default HTTP client.
+const maxRedirects = 10
+
+// ErrBlocked is returned for connections to addresses the policy refuses.
+var ErrBlocked = errors.New("address is not allowed as a webhook target")
+
+// reserved are ranges beyond those the netip predicates cover that must not
+// be reachable: "this network", carrier-grade NAT (where some clouds serve
+// metadata), IETF protocol assignments, benchmarking, the reserved and
+// broadcast block, and NAT64, which embeds arbitrary IPv4 addresses.
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// Policy refuses loopback, private, link-local and other internal addresses
+// unless they are explicitly allowed.
+type Policy struct {
+	allow []netip.Prefix
+}
+
+// NewPolicy creates a policy. allow lists ranges that may be reached even
+// though they are internal, e.g. a test service on the private network.
+func NewPolicy(allow []netip.Prefix) *Policy {
+	return &Policy{allow: allow}
+}
+
+// Allowed reports whether connecting to addr is permitted.
+func (p *Policy) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if utils.ContainsIP(p.allow, addr) {
+		return true
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	return !utils.ContainsIP(reserved, addr)
+}
+
+// control is a net.Dialer hook. It runs after DNS resolution for every
+// connection attempt, so names that resolve to internal addresses, including
+// through DNS rebinding or redirects, are refused too.
+func (p *Policy) control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlocked, address)
+	}
+	if !p.Allowed(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlocked, addrPort.Addr())
+	}
+	return nil
+}
+
+// Client returns an HTTP client that only connects to allowed addresses.
+// Environment proxies are not used, since the policy would then only see the
+// proxy's address.
+func (p *Policy) Client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.control,
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+		// Each redirect opens a connection through the same dialer; this
+		// only bounds the chain and keeps it on HTTP.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("refusing redirect to %s URL", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// CheckURL reports an error if raw is not an http(s) URL or its host
+// resolves to an address the policy refuses. Hosts that do not resolve yet
+// are accepted; connections are checked again when they are made.
+func (p *Policy) CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("URL must be an absolute http or https URL")
+	}
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !p.Allowed(addr) {
+			return fmt.Errorf("%w: %s", ErrBlocked, addr)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !p.Allowed(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrBlocked, host, addr)
+		}
+	}
+	return nil
+}
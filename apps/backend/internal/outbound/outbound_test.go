@@ -0,0 +1,130 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"127.1.2.3", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"fd00:ec2::254", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.100.100.200", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"::ffff:93.184.216.34", true},
+		{"64:ff9b::a9fe:a9fe", false},
+	}
+	p := NewPolicy(nil)
+	for _, tt := range tests {
+		if got := p.Allowed(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Allowed(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestAllowList(t *testing.T) {
+	p := NewPolicy([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/24"), netip.MustParsePrefix("127.0.0.1/32")})
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.0.0.7", true},
+		{"10.0.1.7", false},
+		{"127.0.0.1", true},
+		{"::ffff:127.0.0.1", true},
+		{"127.0.0.2", false},
+		{"169.254.169.254", false},
+	}
+	for _, tt := range tests {
+		if got := p.Allowed(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Allowed(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://93.184.216.34/hook", false},
+		{"http://[2606:2800:220:1:248:1893:25c8:1946]:8080/", false},
+		{"http://169.254.169.254/latest/meta-data/", true},
+		{"http://127.0.0.1:8080/", true},
+		{"http://[::1]/", true},
+		{"http://[::ffff:10.0.0.1]/", true},
+		{"http://localhost/", true},
+		{"ftp://93.184.216.34/", true},
+		{"/relative", true},
+		{"http://", true},
+	}
+	p := NewPolicy(nil)
+	for _, tt := range tests {
+		if err := p.CheckURL(context.Background(), tt.url); (err != nil) != tt.wantErr {
+			t.Errorf("CheckURL(%q) = %v, want error %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer target.Close()
+
+	if _, err := NewPolicy(nil).Client().Get(target.URL); !errors.Is(err, ErrBlocked) {
+		t.Errorf("request to %s: %v, want %v", target.URL, err, ErrBlocked)
+	}
+
+	allowed := NewPolicy([]netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")})
+	resp, err := allowed.Client().Get(target.URL)
+	if err != nil {
+		t.Fatalf("request to allowed %s: %v", target.URL, err)
+	}
+	resp.Body.Close()
+}
+
+func TestClientChecksRedirects(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer internal.Close()
+
+	// The redirecting server is allowed; the target it points to is not.
+	redirector := httptest.NewUnstartedServer(http.RedirectHandler(internal.URL, http.StatusFound))
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("cannot listen on 127.0.0.2: %v", err)
+	}
+	redirector.Listener = listener
+	redirector.Start()
+	defer redirector.Close()
+
+	p := NewPolicy([]netip.Prefix{netip.MustParsePrefix("127.0.0.2/32")})
+	if _, err := p.Client().Get(redirector.URL); !errors.Is(err, ErrBlocked) {
+		t.Errorf("redirect to %s: %v, want %v", internal.URL, err, ErrBlocked)
+	}
+}
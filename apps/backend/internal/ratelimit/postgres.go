@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"hookinator/internal/database"
+)
+
+// Postgres keeps buckets in the rate_limits table so every instance sees
+// the same limits. When Postgres cannot be reached it falls back to an
+// in-process limiter rather than refusing or waving through all traffic.
+type Postgres struct {
+	DB       *database.DB
+	fallback *Memory
+
+	lastPrune atomic.Int64
+}
+
+// NewPostgres creates a limiter backed by the database.
+func NewPostgres(db *database.DB) *Postgres {
+	return &Postgres{DB: db, fallback: NewMemory()}
+}
+
+// Allow takes a token from the bucket named key.
+func (p *Postgres) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	p.maybePrune()
+
+	allowed, tokens, err := p.DB.TakeRateLimitToken(ctx, key, limit.Rate, limit.Burst)
+	if err != nil {
+		log.Printf("Rate limiter falling back to in-process buckets: %v", err)
+		return p.fallback.Allow(ctx, key, limit)
+	}
+	if !allowed {
+		return Result{RetryAfter: retryAfter(tokens, limit)}, nil
+	}
+	return Result{Allowed: true}, nil
+}
+
+// maybePrune deletes idle buckets in the background, at most once per
+// idleAfter per instance.
+func (p *Postgres) maybePrune() {
+	now := time.Now().UnixNano()
+	last := p.lastPrune.Load()
+	if now-last < int64(idleAfter) || !p.lastPrune.CompareAndSwap(last, now) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := p.DB.PruneRateLimits(ctx, idleAfter); err != nil {
+			log.Printf("%v", err)
+		}
+	}()
+}
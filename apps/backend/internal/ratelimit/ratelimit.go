@@ -0,0 +1,90 @@
+// Package ratelimit implements token-bucket rate limits, kept either in
+// process or in Postgres so that several server instances share them.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at Rate per
+// second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	// RetryAfter is when the next token is available, if not allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter takes tokens from named buckets.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// retryAfter is how long a bucket holding tokens takes to refill to one.
+func retryAfter(tokens float64, limit Limit) time.Duration {
+	if limit.Rate <= 0 {
+		return time.Hour
+	}
+	return time.Duration(math.Ceil((1 - tokens) / limit.Rate * float64(time.Second)))
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Memory keeps buckets in process. Limits are per instance.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	// now is the clock, replaced in tests.
+	now func() time.Time
+}
+
+// idleAfter is how long an untouched bucket is kept; by then it has
+// refilled for any sensible limit, so dropping it changes nothing.
+const idleAfter = 10 * time.Minute
+
+// NewMemory creates an in-process limiter.
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket), lastSweep: time.Now(), now: time.Now}
+}
+
+// Allow takes a token from the bucket named key.
+func (m *Memory) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > idleAfter {
+		for k, b := range m.buckets {
+			if now.Sub(b.updated) > idleAfter {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return Result{RetryAfter: retryAfter(b.tokens, limit)}, nil
+	}
+	b.tokens--
+	return Result{Allowed: true}, nil
+}
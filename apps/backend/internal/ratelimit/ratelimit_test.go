@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for the memory limiter.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestMemory() (*Memory, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewMemory()
+	m.now = clock.now
+	m.lastSweep = clock.t
+	return m, clock
+}
+
+func TestMemory(t *testing.T) {
+	// step is one call to Allow, after advancing the clock by advance.
+	type step struct {
+		advance        time.Duration
+		wantAllowed    bool
+		wantRetryAfter time.Duration
+	}
+	tests := []struct {
+		name  string
+		limit Limit
+		steps []step
+	}{
+		{
+			name:  "burst then refused",
+			limit: Limit{Rate: 1, Burst: 3},
+			steps: []step{
+				{wantAllowed: true},
+				{wantAllowed: true},
+				{wantAllowed: true},
+				{wantRetryAfter: time.Second},
+			},
+		},
+		{
+			name:  "refills at rate",
+			limit: Limit{Rate: 2, Burst: 1},
+			steps: []step{
+				{wantAllowed: true},
+				{wantRetryAfter: 500 * time.Millisecond},
+				{advance: 200 * time.Millisecond, wantRetryAfter: 300 * time.Millisecond},
+				{advance: 300 * time.Millisecond, wantAllowed: true},
+				{wantRetryAfter: 500 * time.Millisecond},
+			},
+		},
+		{
+			name:  "refill capped at burst",
+			limit: Limit{Rate: 10, Burst: 2},
+			steps: []step{
+				{wantAllowed: true},
+				{wantAllowed: true},
+				{advance: time.Hour, wantAllowed: true},
+				{wantAllowed: true},
+				{wantRetryAfter: 100 * time.Millisecond},
+			},
+		},
+		{
+			name:  "fractional rate",
+			limit: Limit{Rate: 0.25, Burst: 1},
+			steps: []step{
+				{wantAllowed: true},
+				{advance: time.Second, wantRetryAfter: 3 * time.Second},
+				{advance: 3 * time.Second, wantAllowed: true},
+			},
+		},
+		{
+			name:  "zero rate never refills",
+			limit: Limit{Rate: 0, Burst: 1},
+			steps: []step{
+				{wantAllowed: true},
+				{advance: 5 * time.Minute, wantRetryAfter: time.Hour},
+			},
+		},
+		{
+			name:  "zero burst refuses everything",
+			limit: Limit{Rate: 5, Burst: 0},
+			steps: []step{
+				{wantRetryAfter: 200 * time.Millisecond},
+			},
+		},
+	}
+	for _, tt := range tests {
+		m, clock := newTestMemory()
+		for i, s := range tt.steps {
+			clock.t = clock.t.Add(s.advance)
+			res, err := m.Allow(context.Background(), "key", tt.limit)
+			if err != nil {
+				t.Fatalf("%s: step %d: %v", tt.name, i, err)
+			}
+			if res.Allowed != s.wantAllowed || res.RetryAfter != s.wantRetryAfter {
+				t.Errorf("%s: step %d: got allowed %v, retry after %v; want %v, %v", tt.name, i, res.Allowed, res.RetryAfter, s.wantAllowed, s.wantRetryAfter)
+			}
+		}
+	}
+}
+
+func TestMemoryKeysAreIndependent(t *testing.T) {
+	m, _ := newTestMemory()
+	limit := Limit{Rate: 1, Burst: 1}
+	for _, key := range []string{"ip:192.0.2.1", "ip:192.0.2.2"} {
+		if res, _ := m.Allow(context.Background(), key, limit); !res.Allowed {
+			t.Errorf("first request for %s refused", key)
+		}
+	}
+	if res, _ := m.Allow(context.Background(), "ip:192.0.2.1", limit); res.Allowed {
+		t.Error("second request for ip:192.0.2.1 allowed")
+	}
+}
+
+func TestMemorySweepsIdleBuckets(t *testing.T) {
+	m, clock := newTestMemory()
+	limit := Limit{Rate: 1, Burst: 1}
+	m.Allow(context.Background(), "idle", limit)
+	clock.t = clock.t.Add(idleAfter + time.Second)
+	m.Allow(context.Background(), "active", limit)
+	if _, ok := m.buckets["idle"]; ok {
+		t.Error("idle bucket was not swept")
+	}
+	if _, ok := m.buckets["active"]; !ok {
+		t.Error("active bucket was swept")
+	}
+}
@@ -0,0 +1,134 @@
+// Package rejections counts refused ingestion requests in memory and adds
+// the counts to the database periodically, so a flood of rejected requests
+// does not turn into as many writes to the same rows.
+package rejections
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"hookinator/internal/database"
+	"hookinator/internal/utils"
+)
+
+const (
+	// maxPending caps the distinct webhook IDs and reasons counted between
+	// flushes. Senders choose the IDs, so beyond it rejections are dropped.
+	maxPending = 10000
+	// maxIDBytes is the longest webhook ID stored, as the column allows.
+	maxIDBytes = 255
+	// flushTimeout bounds one attempt to write the counts.
+	flushTimeout = 10 * time.Second
+)
+
+type key struct {
+	webhookID string
+	reason    string
+}
+
+// Counter aggregates rejections until they are flushed.
+type Counter struct {
+	DB       *database.DB
+	Interval time.Duration
+
+	mu      sync.Mutex
+	pending map[key]*database.Rejection
+	dropped int64
+}
+
+// New creates a Counter that flushes every interval once Run is started.
+func New(db *database.DB, interval time.Duration) *Counter {
+	return &Counter{DB: db, Interval: interval, pending: make(map[key]*database.Rejection)}
+}
+
+// Record counts one refused request for a webhook ID. It never blocks on
+// the database.
+func (c *Counter) Record(webhookID, reason string) {
+	// Senders choose the ID, so it may not be storable as it is.
+	webhookID = utils.StorableText(webhookID, maxIDBytes)
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := key{webhookID, reason}
+	if rj, ok := c.pending[k]; ok {
+		rj.Count++
+		rj.LastRejectedAt = now
+		return
+	}
+	if len(c.pending) >= maxPending {
+		c.dropped++
+		return
+	}
+	c.pending[k] = &database.Rejection{WebhookID: webhookID, Reason: reason, Count: 1, LastRejectedAt: now}
+}
+
+// Run flushes the counts every Interval until ctx is cancelled. Call Flush
+// once more after the server has stopped.
+func (c *Counter) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Flush()
+		}
+	}
+}
+
+// Flush writes the counts gathered so far. Counts that fail to be written
+// are kept for the next flush.
+func (c *Counter) Flush() {
+	batch, dropped := c.take()
+	if dropped > 0 {
+		log.Printf("Dropped %d rejections beyond %d distinct webhook IDs", dropped, maxPending)
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	if err := c.DB.RecordRejections(ctx, batch); err != nil {
+		log.Printf("%v", err)
+		c.restore(batch)
+	}
+}
+
+// take removes and returns the pending counts and how many rejections were
+// dropped since the last call.
+func (c *Counter) take() ([]database.Rejection, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	batch := make([]database.Rejection, 0, len(c.pending))
+	for _, rj := range c.pending {
+		batch = append(batch, *rj)
+	}
+	dropped := c.dropped
+	c.pending = make(map[key]*database.Rejection)
+	c.dropped = 0
+	return batch, dropped
+}
+
+// restore adds counts that could not be written back to the pending ones.
+func (c *Counter) restore(batch []database.Rejection) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, failed := range batch {
+		k := key{failed.WebhookID, failed.Reason}
+		if rj, ok := c.pending[k]; ok {
+			rj.Count += failed.Count
+			continue
+		}
+		if len(c.pending) >= maxPending {
+			c.dropped += failed.Count
+			continue
+		}
+		rj := failed
+		c.pending[k] = &rj
+	}
+}
@@ -0,0 +1,77 @@
+package rejections
+
+import (
+	"strconv"
+	"testing"
+
+	"hookinator/internal/database"
+)
+
+func TestRecordAggregates(t *testing.T) {
+	c := New(nil, 0)
+	c.Record("wh_1", "not_found")
+	c.Record("wh_1", "not_found")
+	c.Record("wh_1", "gone")
+	c.Record("wh_2", "not_found")
+
+	batch, dropped := c.take()
+	if dropped != 0 {
+		t.Errorf("dropped = %d, want 0", dropped)
+	}
+	counts := make(map[key]int64)
+	for _, rj := range batch {
+		counts[key{rj.WebhookID, rj.Reason}] = rj.Count
+	}
+	want := map[key]int64{
+		{"wh_1", "not_found"}: 2,
+		{"wh_1", "gone"}:      1,
+		{"wh_2", "not_found"}: 1,
+	}
+	if len(counts) != len(want) {
+		t.Fatalf("counts = %v, want %v", counts, want)
+	}
+	for k, n := range want {
+		if counts[k] != n {
+			t.Errorf("count of %v = %d, want %d", k, counts[k], n)
+		}
+	}
+
+	if batch, _ := c.take(); len(batch) != 0 {
+		t.Errorf("second take = %v, want nothing", batch)
+	}
+}
+
+func TestRecordCapsDistinctIDs(t *testing.T) {
+	c := New(nil, 0)
+	for i := 0; i < maxPending+5; i++ {
+		c.Record("wh_"+strconv.Itoa(i), "not_found")
+	}
+	// Known keys keep counting once the cap is reached.
+	c.Record("wh_0", "not_found")
+
+	batch, dropped := c.take()
+	if len(batch) != maxPending {
+		t.Errorf("len(batch) = %d, want %d", len(batch), maxPending)
+	}
+	if dropped != 5 {
+		t.Errorf("dropped = %d, want 5", dropped)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	c := New(nil, 0)
+	c.Record("wh_1", "not_found")
+	c.restore([]database.Rejection{
+		{WebhookID: "wh_1", Reason: "not_found", Count: 3},
+		{WebhookID: "wh_2", Reason: "gone", Count: 2},
+	})
+
+	batch, _ := c.take()
+	counts := make(map[key]int64)
+	for _, rj := range batch {
+		counts[key{rj.WebhookID, rj.Reason}] = rj.Count
+	}
+	if counts[key{"wh_1", "not_found"}] != 4 || counts[key{"wh_2", "gone"}] != 2 {
+		t.Errorf("counts = %v, want wh_1/not_found 4 and wh_2/gone 2", counts)
+	}
+}
@@ -0,0 +1,130 @@
+package responder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"hookinator/internal/database"
+)
+
+const (
+	// MaxDelay caps the artificial delay of a configured response.
+	MaxDelay = 30 * time.Second
+)
+
+// Data is what response templates can refer to, e.g. {{.Headers.Get
+// "X-Request-Id"}}, {{.Query.Get "challenge"}} or {{.JSON.data.id}}.
+type Data struct {
+	WebhookID string
+	Method    string
+	Path      string
+	Query     url.Values
+	Headers   http.Header
+	Body      string
+	// JSON is the parsed body, or nil if the body is not JSON.
+	JSON      interface{}
+	Timestamp time.Time
+}
+
+// NewData builds template data for a captured request.
+func NewData(webhookID string, req database.WebhookRequest) Data {
+	query, _ := url.ParseQuery(req.Query)
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(req.Body), &parsed); err != nil {
+		parsed = nil
+	}
+	return Data{
+		WebhookID: webhookID,
+		Method:    req.Method,
+		Path:      req.Path,
+		Query:     query,
+		Headers:   req.Headers,
+		Body:      req.Body,
+		JSON:      parsed,
+		Timestamp: req.Timestamp,
+	}
+}
+
+var funcs = template.FuncMap{
+	"toJSON": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// Template is a compiled response configuration.
+type Template struct {
+	status  int
+	headers map[string]*template.Template
+	body    *template.Template
+	delay   time.Duration
+}
+
+// Compile validates a response configuration and parses its templates.
+func Compile(cfg database.ResponseConfig) (*Template, error) {
+	t := &Template{
+		status:  cfg.Status,
+		headers: make(map[string]*template.Template, len(cfg.Headers)),
+		delay:   time.Duration(cfg.DelayMS) * time.Millisecond,
+	}
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+	if t.status < 100 || t.status > 599 {
+		return nil, fmt.Errorf("status must be between 100 and 599")
+	}
+	if t.delay < 0 || t.delay > MaxDelay {
+		return nil, fmt.Errorf("delay_ms must be between 0 and %d", MaxDelay.Milliseconds())
+	}
+
+	for name, value := range cfg.Headers {
+		tmpl, err := template.New(name).Funcs(funcs).Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for header %s: %w", name, err)
+		}
+		t.headers[name] = tmpl
+	}
+	body, err := template.New("body").Funcs(funcs).Parse(cfg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body template: %w", err)
+	}
+	t.body = body
+	return t, nil
+}
+
+// Delay is how long to wait before responding.
+func (t *Template) Delay() time.Duration {
+	return t.delay
+}
+
+// Write renders the response for data and writes it to w. Nothing is written
+// if rendering fails.
+func (t *Template) Write(w http.ResponseWriter, data Data) error {
+	headers := make(map[string]string, len(t.headers))
+	for name, tmpl := range t.headers {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return fmt.Errorf("failed to render header %s: %w", name, err)
+		}
+		headers[name] = buf.String()
+	}
+	var body bytes.Buffer
+	if err := t.body.Execute(&body, data); err != nil {
+		return fmt.Errorf("failed to render body: %w", err)
+	}
+
+	for name, value := range headers {
+		w.Header().Set(name, value)
+	}
+	w.WriteHeader(t.status)
+	w.Write(body.Bytes())
+	return nil
+}
@@ -0,0 +1,138 @@
+package responder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"hookinator/internal/database"
+)
+
+func testData() Data {
+	return NewData("wh_1", database.WebhookRequest{
+		Method:    "POST",
+		Path:      "/events",
+		Query:     "challenge=abc&tag=a&tag=b",
+		Headers:   http.Header{"X-Request-Id": {"req-42"}},
+		Body:      `{"data": {"id": "evt_1", "count": 3}, "Type": "Ping"}`,
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  database.ResponseConfig
+	}{
+		{"status too low", database.ResponseConfig{Status: 99}},
+		{"status too high", database.ResponseConfig{Status: 600}},
+		{"negative delay", database.ResponseConfig{DelayMS: -1}},
+		{"delay too long", database.ResponseConfig{DelayMS: int(MaxDelay.Milliseconds()) + 1}},
+		{"bad body template", database.ResponseConfig{Body: "{{.Body"}},
+		{"bad header template", database.ResponseConfig{Headers: map[string]string{"X-A": "{{end}}"}}},
+		{"unknown function", database.ResponseConfig{Body: "{{nope .Body}}"}},
+	}
+	for _, tt := range tests {
+		if _, err := Compile(tt.cfg); err == nil {
+			t.Errorf("%s: Compile succeeded, want error", tt.name)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        database.ResponseConfig
+		wantStatus int
+		wantHeader map[string]string
+		wantBody   string
+	}{
+		{
+			name:       "default status",
+			cfg:        database.ResponseConfig{Body: "ok"},
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+		{
+			name:       "query challenge",
+			cfg:        database.ResponseConfig{Status: 202, Body: `{{.Query.Get "challenge"}}`},
+			wantStatus: 202,
+			wantBody:   "abc",
+		},
+		{
+			name:       "request fields",
+			cfg:        database.ResponseConfig{Body: "{{.WebhookID}} {{.Method}} {{.Path}} {{.Timestamp.Unix}}"},
+			wantStatus: http.StatusOK,
+			wantBody:   "wh_1 POST /events 1704164645",
+		},
+		{
+			name: "header templates",
+			cfg: database.ResponseConfig{
+				Headers: map[string]string{"X-Echo": `{{.Headers.Get "X-Request-Id"}}`, "Content-Type": "text/plain"},
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{"X-Echo": "req-42", "Content-Type": "text/plain"},
+		},
+		{
+			name:       "JSON body fields",
+			cfg:        database.ResponseConfig{Body: "{{.JSON.data.id}} {{.JSON.data.count}}"},
+			wantStatus: http.StatusOK,
+			wantBody:   "evt_1 3",
+		},
+		{
+			name:       "functions",
+			cfg:        database.ResponseConfig{Body: `{{toJSON .JSON.data}} {{lower .JSON.Type}} {{upper .JSON.Type}} {{toJSON (index .Query "tag")}}`},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"count":3,"id":"evt_1"} ping PING ["a","b"]`,
+		},
+	}
+	for _, tt := range tests {
+		tmpl, err := Compile(tt.cfg)
+		if err != nil {
+			t.Errorf("%s: Compile: %v", tt.name, err)
+			continue
+		}
+		rec := httptest.NewRecorder()
+		if err := tmpl.Write(rec, testData()); err != nil {
+			t.Errorf("%s: Write: %v", tt.name, err)
+			continue
+		}
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		for name, want := range tt.wantHeader {
+			if got := rec.Header().Get(name); got != want {
+				t.Errorf("%s: header %s = %q, want %q", tt.name, name, got, want)
+			}
+		}
+		if got := rec.Body.String(); got != tt.wantBody {
+			t.Errorf("%s: body = %q, want %q", tt.name, got, tt.wantBody)
+		}
+	}
+}
+
+func TestWriteRenderError(t *testing.T) {
+	tmpl, err := Compile(database.ResponseConfig{
+		Status:  201,
+		Headers: map[string]string{"X-Ok": "fine"},
+		Body:    "{{.Missing}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	if err := tmpl.Write(rec, testData()); err == nil {
+		t.Fatal("Write succeeded, want error")
+	}
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Ok") != "" || rec.Body.Len() != 0 {
+		t.Errorf("Write wrote a partial response: status %d, headers %v, body %q", rec.Code, rec.Header(), rec.Body.String())
+	}
+}
+
+func TestNewDataNonJSON(t *testing.T) {
+	data := NewData("wh_1", database.WebhookRequest{Body: "a=1&b=2"})
+	if data.JSON != nil {
+		t.Errorf("JSON = %v, want nil for a form body", data.JSON)
+	}
+}
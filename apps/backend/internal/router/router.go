@@ -2,11 +2,17 @@ package router
 
 import (
 	"net/http"
+	"net/netip"
 	"os"
 	"strings"
 
 	"hookinator/internal/database"
+	"hookinator/internal/forwarder"
+	"hookinator/internal/ingest"
 	"hookinator/internal/handlers"
+	"hookinator/internal/rejections"
+	"hookinator/internal/secrets"
+	"hookinator/internal/stream"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -14,7 +20,7 @@ import (
 )
 
 // The function signature is updated to accept the new configuration
-func New(db *database.DB, baseURL, jwtSecret string) http.Handler {
+func New(db *database.DB, fwd *forwarder.Forwarder, buffer *ingest.Buffer, hub *stream.Hub, rejectionCounter *rejections.Counter, secretBox *secrets.Box, baseURL, jwtSecret string, trustedProxies []netip.Prefix, limits handlers.IngestLimits, retention database.RetentionPolicy) http.Handler {
 	r := chi.NewRouter()
 
 	// Get CORS origins from environment variable
@@ -36,7 +42,8 @@ func New(db *database.DB, baseURL, jwtSecret string) http.Handler {
 	r.Use(middleware.Recoverer)
 
 	// Pass all dependencies to the handlers
-	h := handlers.New(db, baseURL, jwtSecret)
+	h := handlers.New(db, fwd, buffer, hub, rejectionCounter, secretBox, baseURL, jwtSecret, trustedProxies, limits, retention)
+	h.AllowedOrigins = corsOrigins
 
 	// --- Public Routes (No login required) ---
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -45,8 +52,17 @@ func New(db *database.DB, baseURL, jwtSecret string) http.Handler {
 	// The login handler will be public
 	r.Post("/auth/google/login", h.HandleGoogleLogin)
 
-	// Receiving webhooks must be public
-	r.Post("/webhook/{id}", h.HandleWebhook)
+	// Receiving webhooks must be public. Ingestion lives under its own prefix
+	// so any method and sub-path can be accepted without clashing with the
+	// authenticated /webhook/{id} management routes; POST /webhook/{id} is
+	// kept for URLs handed out before the move.
+	r.With(h.ShedLoad).HandleFunc("/hooks/{id}", h.HandleWebhook)
+	r.With(h.ShedLoad).HandleFunc("/hooks/{id}/*", h.HandleWebhook)
+	r.With(h.ShedLoad).Post("/webhook/{id}", h.HandleWebhook)
+
+	// Live streams also accept a stream ticket in place of the token, since
+	// browsers cannot set headers on EventSource or WebSocket connections.
+	r.With(h.StreamAuth).Get("/inspect/{id}/stream", h.StreamRequests)
 
 	// --- Protected Routes (Login required) ---
 	r.Group(func(r chi.Router) {
@@ -57,9 +73,68 @@ func New(db *database.DB, baseURL, jwtSecret string) http.Handler {
 		r.Get("/webhook/{id}", h.GetWebhook)
 		r.Put("/webhook/{id}", h.UpdateWebhook)
 		r.Delete("/webhook/{id}", h.DeleteWebhook)
+		r.Get("/webhook/{id}/response", h.GetWebhookResponse)
+		r.Put("/webhook/{id}/response", h.UpdateWebhookResponse)
+		r.Delete("/webhook/{id}/response", h.ResetWebhookResponse)
+		r.Get("/webhook/{id}/verification", h.GetVerification)
+		r.Put("/webhook/{id}/verification", h.UpdateVerification)
+		r.Delete("/webhook/{id}/verification", h.DisableVerification)
+		r.Get("/webhook/{id}/dedup", h.GetDedupConfig)
+		r.Put("/webhook/{id}/dedup", h.UpdateDedupConfig)
+		r.Delete("/webhook/{id}/dedup", h.DisableDedup)
+		r.Get("/webhook/{id}/ip-filter", h.GetIPFilter)
+		r.Put("/webhook/{id}/ip-filter", h.UpdateIPFilter)
+		r.Delete("/webhook/{id}/ip-filter", h.RemoveIPFilter)
+		r.Get("/webhook/{id}/status", h.GetWebhookStatus)
+		r.Put("/webhook/{id}/status", h.UpdateWebhookStatus)
+		r.Get("/webhook/{id}/schema", h.GetSchemaConfig)
+		r.Put("/webhook/{id}/schema", h.UpdateSchemaConfig)
+		r.Delete("/webhook/{id}/schema", h.RemoveSchemaConfig)
+		r.Get("/webhook/{id}/event-type", h.GetEventTypeConfig)
+		r.Put("/webhook/{id}/event-type", h.UpdateEventTypeConfig)
+		r.Delete("/webhook/{id}/event-type", h.RemoveEventTypeConfig)
+		r.Get("/webhook/{id}/auth", h.GetIngestAuth)
+		r.Put("/webhook/{id}/auth", h.UpdateIngestAuth)
+		r.Delete("/webhook/{id}/auth", h.RemoveIngestAuth)
+		r.Get("/webhook/{id}/blocked", h.ListBlockedRequests)
+		r.Get("/webhook/{id}/rate-limit", h.GetRateLimit)
+		r.Put("/webhook/{id}/rate-limit", h.UpdateRateLimit)
+		r.Delete("/webhook/{id}/rate-limit", h.ResetRateLimit)
+		r.Get("/webhook/{id}/retention", h.GetRetention)
+		r.Put("/webhook/{id}/retention", h.UpdateRetention)
+		r.Delete("/webhook/{id}/retention", h.ResetRetention)
+		r.Get("/webhook/{id}/secrets", h.ListSecrets)
+		r.Post("/webhook/{id}/secrets", h.CreateSecret)
+		r.Post("/webhook/{id}/secrets/rotate", h.RotateSecret)
+		r.Post("/webhook/{id}/secrets/{secretId}/reveal", h.RevealSecret)
+		r.Delete("/webhook/{id}/secrets/{secretId}", h.RevokeSecret)
+		r.Get("/webhook/{id}/destinations", h.ListDestinations)
+		r.Post("/webhook/{id}/destinations", h.CreateDestination)
+		r.Put("/webhook/{id}/destinations/{destinationId}", h.UpdateDestination)
+		r.Delete("/webhook/{id}/destinations/{destinationId}", h.DeleteDestination)
+		r.Get("/webhook/{id}/stubs", h.ListStubs)
+		r.Post("/webhook/{id}/stubs", h.CreateStub)
+		r.Put("/webhook/{id}/stubs/{stubId}", h.UpdateStub)
+		r.Delete("/webhook/{id}/stubs/{stubId}", h.DeleteStub)
+		r.Get("/webhook/{id}/scenarios", h.ListScenarios)
+		r.Post("/webhook/{id}/scenarios/reset", h.ResetScenarios)
 		r.Get("/inspect/{id}", h.InspectWebhook)
+		r.Get("/inspect/{id}/event-types", h.ListEventTypes)
+		r.Post("/inspect/{id}/stream/ticket", h.CreateStreamTicket)
+		r.Get("/inspect/{id}/requests/{requestId}", h.GetWebhookRequest)
+		r.Delete("/inspect/{id}/requests/{requestId}", h.DeleteWebhookRequest)
+		r.Put("/inspect/{id}/requests/{requestId}/pin", h.PinWebhookRequest)
+		r.Delete("/inspect/{id}/requests/{requestId}/pin", h.UnpinWebhookRequest)
+		r.Get("/inspect/{id}/requests/{requestId}/deliveries", h.ListDeliveries)
+		r.Post("/inspect/{id}/requests/{requestId}/replay", h.ReplayRequest)
+		r.Post("/inspect/{id}/replay", h.BulkReplay)
+		r.Get("/inspect/{id}/replay/{replayId}", h.GetBulkReplay)
+		r.Delete("/inspect/{id}/replay/{replayId}", h.CancelBulkReplay)
 		r.Delete("/inspect/{id}/clear", h.ClearWebhookRequests)
 		r.Get("/webhooks", h.ListWebhooks)
+		r.Get("/webhooks/rejections", h.ListRejections)
+		r.Get("/search", h.SearchRequests)
+		r.Get("/ip-presets", h.ListIPPresets)
 	})
 
 	return r
@@ -0,0 +1,118 @@
+// Package schema validates incoming payloads against a webhook's JSON
+// Schema.
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"hookinator/internal/database"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// Actions taken on payloads that fail validation.
+const (
+	// ActionRecord only stores the errors with the request.
+	ActionRecord = "record"
+	// ActionReject answers 422 without capturing the request.
+	ActionReject = "reject"
+	// ActionSkipForwarding captures the request but does not deliver it.
+	ActionSkipForwarding = "skip_forwarding"
+)
+
+// maxErrors caps the errors kept per request; a payload of the wrong shape
+// can fail every keyword of a large schema.
+const maxErrors = 20
+
+// resourceURL names the schema being compiled; relative references resolve
+// against it.
+const resourceURL = "hookinator:///schema.json"
+
+// Validator checks payloads against a compiled schema.
+type Validator struct {
+	schema *jsonschema.Schema
+	action string
+}
+
+// noLoader refuses to load referenced schemas, so a schema can only $ref
+// itself and the bundled meta-schemas rather than local files or URLs.
+type noLoader struct{}
+
+func (noLoader) Load(url string) (any, error) {
+	return nil, fmt.Errorf("external reference %q is not allowed", url)
+}
+
+// Compile compiles a webhook's schema config.
+func Compile(cfg database.SchemaConfig) (*Validator, error) {
+	switch cfg.Action {
+	case "":
+		cfg.Action = ActionRecord
+	case ActionRecord, ActionReject, ActionSkipForwarding:
+	default:
+		return nil, fmt.Errorf("action must be record, reject or skip_forwarding")
+	}
+	if len(cfg.Schema) == 0 {
+		return nil, fmt.Errorf("schema is required")
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(cfg.Schema))
+	if err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	c := jsonschema.NewCompiler()
+	c.UseLoader(noLoader{})
+	if err := c.AddResource(resourceURL, doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	compiled, err := c.Compile(resourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &Validator{schema: compiled, action: cfg.Action}, nil
+}
+
+// Action returns what to do with payloads that fail validation.
+func (v *Validator) Action() string {
+	return v.action
+}
+
+// Validate checks a body against the schema and returns why it does not
+// conform, or nil if it does.
+func (v *Validator) Validate(body []byte) []database.ValidationError {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return []database.ValidationError{{Path: "", Message: "body is not valid JSON"}}
+	}
+	err = v.schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []database.ValidationError{{Path: "", Message: err.Error()}}
+	}
+
+	var errs []database.ValidationError
+	collect(*verr.BasicOutput(), &errs)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	if len(errs) > maxErrors {
+		errs = errs[:maxErrors]
+	}
+	return errs
+}
+
+// collect flattens an output unit into the errors of its leaves; the
+// intermediate units only say that a subschema failed.
+func collect(unit jsonschema.OutputUnit, errs *[]database.ValidationError) {
+	if len(unit.Errors) == 0 {
+		if unit.Error != nil {
+			*errs = append(*errs, database.ValidationError{Path: unit.InstanceLocation, Message: unit.Error.String()})
+		}
+		return
+	}
+	for _, child := range unit.Errors {
+		collect(child, errs)
+	}
+}
@@ -0,0 +1,99 @@
+// Package secrets encrypts webhook secrets at rest with a server master key
+// and generates new ones.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the length of the master key: AES-256.
+const KeySize = 32
+
+// ErrNotConfigured is returned by a nil Box, which is what the server runs
+// with when no master key is set.
+var ErrNotConfigured = errors.New("secret storage is not configured")
+
+// Box seals and opens secrets with AES-GCM. Each ciphertext is bound to the
+// webhook it belongs to, so rows cannot be swapped between webhooks.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// ParseKey decodes a master key given as base64 or hex.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, decode := range []func(string) ([]byte, error){
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		base64.URLEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+		hex.DecodeString,
+	} {
+		if key, err := decode(s); err == nil && len(key) == KeySize {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("master key must be %d bytes encoded as base64 or hex", KeySize)
+}
+
+// New creates a Box from a master key.
+func New(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts a secret of a webhook. The nonce is prepended to the
+// ciphertext.
+func (b *Box) Seal(webhookID, secret string) ([]byte, error) {
+	if b == nil {
+		return nil, ErrNotConfigured
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return b.aead.Seal(nonce, nonce, []byte(secret), []byte(webhookID)), nil
+}
+
+// Open decrypts a secret sealed for the webhook.
+func (b *Box) Open(webhookID string, ciphertext []byte) (string, error) {
+	if b == nil {
+		return "", ErrNotConfigured
+	}
+	n := b.aead.NonceSize()
+	if len(ciphertext) < n {
+		return "", errors.New("ciphertext too short")
+	}
+	plaintext, err := b.aead.Open(nil, ciphertext[:n], ciphertext[n:], []byte(webhookID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Generate returns a new random secret in the Standard Webhooks format,
+// "whsec_" followed by 32 base64-encoded bytes.
+func Generate() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return "whsec_" + base64.StdEncoding.EncodeToString(key), nil
+}
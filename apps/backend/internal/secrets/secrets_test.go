@@ -0,0 +1,146 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testBox(t *testing.T) *Box {
+	t.Helper()
+	box, err := New(bytes.Repeat([]byte{7}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
+
+func TestSealOpen(t *testing.T) {
+	box := testBox(t)
+	for _, secret := range []string{"", "s3cret", "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw", strings.Repeat("ü", 1000)} {
+		sealed, err := box.Seal("wh_1", secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if secret != "" && bytes.Contains(sealed, []byte(secret)) {
+			t.Errorf("ciphertext of %q contains the plaintext", secret)
+		}
+		opened, err := box.Open("wh_1", sealed)
+		if err != nil {
+			t.Errorf("Open(Seal(%q)): %v", secret, err)
+			continue
+		}
+		if opened != secret {
+			t.Errorf("Open(Seal(%q)) = %q", secret, opened)
+		}
+	}
+}
+
+func TestSealUsesFreshNonces(t *testing.T) {
+	box := testBox(t)
+	a, _ := box.Seal("wh_1", "same")
+	b, _ := box.Seal("wh_1", "same")
+	if bytes.Equal(a, b) {
+		t.Error("sealing the same secret twice gave the same ciphertext")
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	box := testBox(t)
+	sealed, err := box.Seal("wh_1", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := New(bytes.Repeat([]byte{8}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name       string
+		box        *Box
+		webhookID  string
+		ciphertext []byte
+	}{
+		{"other webhook", box, "wh_2", sealed},
+		{"empty webhook ID", box, "", sealed},
+		{"other master key", otherKey, "wh_1", sealed},
+		{"tampered", box, "wh_1", tampered},
+		{"truncated", box, "wh_1", sealed[:len(sealed)-1]},
+		{"shorter than a nonce", box, "wh_1", sealed[:4]},
+	}
+	for _, tt := range tests {
+		if got, err := tt.box.Open(tt.webhookID, tt.ciphertext); err == nil {
+			t.Errorf("%s: Open = %q, want error", tt.name, got)
+		}
+	}
+}
+
+func TestNilBox(t *testing.T) {
+	var box *Box
+	if _, err := box.Seal("wh_1", "s3cret"); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Seal = %v, want %v", err, ErrNotConfigured)
+	}
+	if _, err := box.Open("wh_1", []byte("ciphertext")); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Open = %v, want %v", err, ErrNotConfigured)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key := make([]byte, KeySize)
+	for i := range key {
+		// Bytes that base64-encode to '+', '/', '-' and '_'.
+		key[i] = byte(0xF8 + i%8)
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  []byte
+	}{
+		{"hex", hex.EncodeToString(key), key},
+		{"upper-case hex", strings.ToUpper(hex.EncodeToString(key)), key},
+		{"base64", base64.StdEncoding.EncodeToString(key), key},
+		{"unpadded base64", base64.RawStdEncoding.EncodeToString(key), key},
+		{"URL-safe base64", base64.URLEncoding.EncodeToString(key), key},
+		{"unpadded URL-safe base64", base64.RawURLEncoding.EncodeToString(key), key},
+		{"surrounding whitespace", " " + hex.EncodeToString(key) + "\n", key},
+		{"hex too short", hex.EncodeToString(key[:16]), nil},
+		{"base64 too long", base64.StdEncoding.EncodeToString(append(key, 0)), nil},
+		{"not encoded", "not a key", nil},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		got, err := ParseKey(tt.input)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: ParseKey succeeded, want error", tt.name)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: ParseKey = %x, %v; want %x", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestNewRejectsShortKey(t *testing.T) {
+	if _, err := New(make([]byte, 16)); err == nil {
+		t.Error("New accepted a 16-byte key")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	secret, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if !strings.HasPrefix(secret, "whsec_") || err != nil || len(key) != 32 {
+		t.Errorf("Generate = %q, want whsec_ and 32 base64 bytes", secret)
+	}
+}
@@ -0,0 +1,16 @@
+// Package sourcetype names the providers a webhook can receive from.
+package sourcetype
+
+import "strings"
+
+// Normalize returns the canonical name of a source type, so that the
+// packages with per-provider rules agree on which provider a webhook uses:
+// it is lower-cased and trimmed, and aliases map to one name.
+func Normalize(sourceType string) string {
+	s := strings.ToLower(strings.TrimSpace(sourceType))
+	switch s {
+	case "standard_webhooks", "standardwebhooks", "svix":
+		return "standard-webhooks"
+	}
+	return s
+}
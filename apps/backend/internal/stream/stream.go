@@ -0,0 +1,169 @@
+// Package stream pushes newly captured requests to live subscribers.
+//
+// Every instance listens for the notifications sent when requests are saved,
+// so a subscriber sees the requests captured by any instance.
+package stream
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"hookinator/internal/database"
+)
+
+// subscriberBuffer is how many requests a subscriber may fall behind before
+// it is dropped. Dropped subscribers reconnect and resume from the last
+// request they received.
+const subscriberBuffer = 256
+
+const (
+	minRetry = time.Second
+	maxRetry = 30 * time.Second
+)
+
+// Hub fans saved requests out to the subscribers of their webhook.
+type Hub struct {
+	DB *database.DB
+
+	mu     sync.Mutex
+	subs   map[string]map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription receives the requests of one webhook as they are saved. C is
+// closed when the subscriber falls behind, the notification connection is
+// lost or the hub is closed; the subscriber should then resume from the
+// last request it received.
+type Subscription struct {
+	C <-chan database.WebhookRequest
+
+	c         chan database.WebhookRequest
+	hub       *Hub
+	webhookID string
+}
+
+// New creates a Hub. Run must be called for it to receive requests.
+func New(db *database.DB) *Hub {
+	return &Hub{DB: db, subs: make(map[string]map[*Subscription]struct{})}
+}
+
+// Run listens for saved requests until ctx is cancelled, reconnecting with
+// backoff when the connection fails.
+func (h *Hub) Run(ctx context.Context) {
+	retry := minRetry
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := h.DB.ListenRequests(ctx, func(n database.RequestNotification) {
+			h.dispatch(ctx, n)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Stream: %v", err)
+		// Requests saved while reconnecting are never announced, so
+		// subscribers must catch up on their own.
+		h.dropAll()
+
+		if time.Since(started) > maxRetry {
+			retry = minRetry
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, maxRetry)
+	}
+}
+
+// Subscribe starts receiving the requests of a webhook. It returns nil if
+// the hub is closed.
+func (h *Hub) Subscribe(webhookID string) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	c := make(chan database.WebhookRequest, subscriberBuffer)
+	s := &Subscription{C: c, c: c, hub: h, webhookID: webhookID}
+	if h.subs[webhookID] == nil {
+		h.subs[webhookID] = make(map[*Subscription]struct{})
+	}
+	h.subs[webhookID][s] = struct{}{}
+	return s
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Close ends every subscription and refuses new ones. It is called on
+// shutdown so that open streams do not hold the server up.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	h.removeAll()
+}
+
+func (h *Hub) dropAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeAll()
+}
+
+func (h *Hub) removeAll() {
+	for _, subs := range h.subs {
+		for s := range subs {
+			h.remove(s)
+		}
+	}
+}
+
+// remove closes a subscription if it is still open. h.mu must be held.
+func (h *Hub) remove(s *Subscription) {
+	subs := h.subs[s.webhookID]
+	if _, ok := subs[s]; !ok {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(h.subs, s.webhookID)
+	}
+	close(s.c)
+}
+
+func (h *Hub) dispatch(ctx context.Context, n database.RequestNotification) {
+	h.mu.Lock()
+	watched := len(h.subs[n.WebhookID]) > 0
+	h.mu.Unlock()
+	if !watched {
+		return
+	}
+
+	req, err := h.DB.GetRequest(ctx, n.WebhookID, n.RequestID)
+	if err != nil {
+		// The request may have been deleted since it was saved.
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Stream: failed to load request %d: %v", n.RequestID, err)
+		}
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs[n.WebhookID] {
+		select {
+		case s.c <- req:
+		default:
+			h.remove(s)
+		}
+	}
+}
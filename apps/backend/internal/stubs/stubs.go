@@ -0,0 +1,238 @@
+package stubs
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"hookinator/internal/database"
+	"hookinator/internal/jsonpath"
+	"hookinator/internal/responder"
+)
+
+// Fault types a stub can simulate.
+const (
+	FaultTimeout         = "timeout"
+	FaultConnectionReset = "connection_reset"
+	FaultEmptyResponse   = "empty_response"
+	FaultErrorBurst      = "error_burst"
+)
+
+// MaxFaultTimeout caps how long a timeout fault holds a connection open.
+const MaxFaultTimeout = 2 * time.Minute
+
+type valueMatcher struct {
+	equalTo  *string
+	contains string
+	matches  *regexp.Regexp
+	absent   bool
+}
+
+func compileValue(m database.ValueMatcher, what string) (valueMatcher, error) {
+	vm := valueMatcher{equalTo: m.EqualTo, contains: m.Contains, absent: m.Absent}
+	if m.Matches != "" {
+		re, err := regexp.Compile(m.Matches)
+		if err != nil {
+			return valueMatcher{}, fmt.Errorf("invalid regular expression for %s: %w", what, err)
+		}
+		vm.matches = re
+	}
+	return vm, nil
+}
+
+// test reports whether a value (present says whether it exists at all)
+// satisfies the matcher.
+func (m valueMatcher) test(value string, present bool) bool {
+	if m.absent {
+		return !present
+	}
+	if !present {
+		return false
+	}
+	if m.equalTo != nil && value != *m.equalTo {
+		return false
+	}
+	if m.contains != "" && !strings.Contains(value, m.contains) {
+		return false
+	}
+	if m.matches != nil && !m.matches.MatchString(value) {
+		return false
+	}
+	return true
+}
+
+type bodyMatcher struct {
+	path jsonpath.Path
+	valueMatcher
+}
+
+// Stub is a compiled stub ready for matching.
+type Stub struct {
+	database.Stub
+
+	path     *valueMatcher
+	headers  map[string]valueMatcher
+	query    map[string]valueMatcher
+	body     []bodyMatcher
+	response *responder.Template
+}
+
+// Compile validates a stub and prepares its matchers and response.
+func Compile(s database.Stub) (*Stub, error) {
+	c := &Stub{
+		Stub:    s,
+		headers: make(map[string]valueMatcher, len(s.Match.Headers)),
+		query:   make(map[string]valueMatcher, len(s.Match.Query)),
+	}
+
+	if s.Match.Path != nil {
+		vm, err := compileValue(*s.Match.Path, "path")
+		if err != nil {
+			return nil, err
+		}
+		c.path = &vm
+	}
+	for name, m := range s.Match.Headers {
+		vm, err := compileValue(m, "header "+name)
+		if err != nil {
+			return nil, err
+		}
+		c.headers[http.CanonicalHeaderKey(name)] = vm
+	}
+	for name, m := range s.Match.Query {
+		vm, err := compileValue(m, "query parameter "+name)
+		if err != nil {
+			return nil, err
+		}
+		c.query[name] = vm
+	}
+	for _, m := range s.Match.Body {
+		path, err := jsonpath.Parse(m.Path)
+		if err != nil {
+			return nil, err
+		}
+		vm, err := compileValue(m.ValueMatcher, "body path "+m.Path)
+		if err != nil {
+			return nil, err
+		}
+		c.body = append(c.body, bodyMatcher{path: path, valueMatcher: vm})
+	}
+
+	if s.Fault != nil {
+		switch s.Fault.Type {
+		case FaultTimeout:
+			if s.Fault.DurationMS < 0 || time.Duration(s.Fault.DurationMS)*time.Millisecond > MaxFaultTimeout {
+				return nil, fmt.Errorf("fault duration_ms must be between 0 and %d", MaxFaultTimeout.Milliseconds())
+			}
+		case FaultConnectionReset, FaultEmptyResponse:
+		case FaultErrorBurst:
+			if s.Fault.Burst < 1 {
+				return nil, fmt.Errorf("error_burst fault needs a burst of at least 1")
+			}
+			if s.Fault.Period != 0 && s.Fault.Period < s.Fault.Burst {
+				return nil, fmt.Errorf("error_burst fault period must be at least the burst")
+			}
+			if s.Fault.Status != 0 && (s.Fault.Status < 400 || s.Fault.Status > 599) {
+				return nil, fmt.Errorf("error_burst fault status must be between 400 and 599")
+			}
+		default:
+			return nil, fmt.Errorf("unknown fault type %q", s.Fault.Type)
+		}
+	}
+
+	if (s.RequiredState != "" || s.NewState != "") && s.Scenario == "" {
+		return nil, fmt.Errorf("required_state and new_state need a scenario")
+	}
+
+	response, err := responder.Compile(s.Response)
+	if err != nil {
+		return nil, err
+	}
+	c.response = response
+	return c, nil
+}
+
+// Response is the stub's compiled response.
+func (s *Stub) Response() *responder.Template {
+	return s.response
+}
+
+// Matches reports whether the request described by data satisfies every
+// condition of the stub.
+func (s *Stub) Matches(data responder.Data) bool {
+	if !s.Enabled {
+		return false
+	}
+	if s.Match.Method != "" && s.Match.Method != "ANY" && !strings.EqualFold(s.Match.Method, data.Method) {
+		return false
+	}
+	if s.path != nil && !s.path.test(data.Path, true) {
+		return false
+	}
+	for name, m := range s.headers {
+		values, present := data.Headers[name]
+		if !m.test(strings.Join(values, ","), present) {
+			return false
+		}
+	}
+	for name, m := range s.query {
+		values, present := data.Query[name]
+		if !m.test(strings.Join(values, ","), present) {
+			return false
+		}
+	}
+	for _, m := range s.body {
+		value, present := m.path.LookupString(data.JSON)
+		if !m.test(value, present) {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns the first stub matching data whose scenario, if any, is in
+// the required state. states maps scenario names to their current state;
+// missing scenarios are in database.ScenarioStarted.
+func Select(stubs []*Stub, data responder.Data, states map[string]string) *Stub {
+	for _, s := range stubs {
+		if s.Scenario != "" && s.RequiredState != "" {
+			state, ok := states[s.Scenario]
+			if !ok {
+				state = database.ScenarioStarted
+			}
+			if state != s.RequiredState {
+				continue
+			}
+		}
+		if s.Matches(data) {
+			return s
+		}
+	}
+	return nil
+}
+
+// UsesScenarios reports whether any stub depends on scenario state.
+func UsesScenarios(stubs []*Stub) bool {
+	for _, s := range stubs {
+		if s.Scenario != "" && s.RequiredState != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// InBurst reports whether the hits-th match of an error_burst stub should
+// fail.
+func (s *Stub) InBurst(hits int64) bool {
+	f := s.Fault
+	if f == nil || f.Type != FaultErrorBurst {
+		return false
+	}
+	n := hits - 1
+	if f.Period > 0 {
+		n %= int64(f.Period)
+	}
+	return n < int64(f.Burst)
+}
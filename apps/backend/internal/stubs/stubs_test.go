@@ -0,0 +1,173 @@
+package stubs
+
+import (
+	"net/http"
+	"testing"
+
+	"hookinator/internal/database"
+	"hookinator/internal/responder"
+)
+
+func str(s string) *string { return &s }
+
+func request() responder.Data {
+	return responder.NewData("wh_1", database.WebhookRequest{
+		Method:  "POST",
+		Path:    "/orders/42",
+		Query:   "mode=test&tag=a&tag=b",
+		Headers: http.Header{"X-Github-Event": {"push"}, "Content-Type": {"application/json"}},
+		Body:    `{"action": "created", "order": {"id": 42, "total": "9.99"}, "note": null}`,
+	})
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		match database.StubMatch
+		want  bool
+	}{
+		{"empty matches everything", database.StubMatch{}, true},
+		{"method", database.StubMatch{Method: "post"}, true},
+		{"any method", database.StubMatch{Method: "ANY"}, true},
+		{"other method", database.StubMatch{Method: "GET"}, false},
+		{"path equal", database.StubMatch{Path: &database.ValueMatcher{EqualTo: str("/orders/42")}}, true},
+		{"path not equal", database.StubMatch{Path: &database.ValueMatcher{EqualTo: str("/orders")}}, false},
+		{"path regex", database.StubMatch{Path: &database.ValueMatcher{Matches: `^/orders/\d+$`}}, true},
+		{"path contains", database.StubMatch{Path: &database.ValueMatcher{Contains: "orders"}}, true},
+		{"header any case", database.StubMatch{Headers: map[string]database.ValueMatcher{"x-github-event": {EqualTo: str("push")}}}, true},
+		{"header mismatch", database.StubMatch{Headers: map[string]database.ValueMatcher{"X-GitHub-Event": {EqualTo: str("ping")}}}, false},
+		{"header missing", database.StubMatch{Headers: map[string]database.ValueMatcher{"X-Missing": {Contains: ""}}}, false},
+		{"header absent", database.StubMatch{Headers: map[string]database.ValueMatcher{"X-Missing": {Absent: true}}}, true},
+		{"header present but absent wanted", database.StubMatch{Headers: map[string]database.ValueMatcher{"Content-Type": {Absent: true}}}, false},
+		{"query", database.StubMatch{Query: map[string]database.ValueMatcher{"mode": {EqualTo: str("test")}}}, true},
+		{"repeated query joined", database.StubMatch{Query: map[string]database.ValueMatcher{"tag": {EqualTo: str("a,b")}}}, true},
+		{"query missing", database.StubMatch{Query: map[string]database.ValueMatcher{"other": {Matches: ".*"}}}, false},
+		{"body string", database.StubMatch{Body: []database.BodyMatcher{{Path: "$.action", ValueMatcher: database.ValueMatcher{EqualTo: str("created")}}}}, true},
+		{"body number", database.StubMatch{Body: []database.BodyMatcher{{Path: "$.order.id", ValueMatcher: database.ValueMatcher{EqualTo: str("42")}}}}, true},
+		{"body mismatch", database.StubMatch{Body: []database.BodyMatcher{{Path: "$.action", ValueMatcher: database.ValueMatcher{EqualTo: str("deleted")}}}}, false},
+		{"body null is absent", database.StubMatch{Body: []database.BodyMatcher{{Path: "$.note", ValueMatcher: database.ValueMatcher{Absent: true}}}}, true},
+		{"body missing", database.StubMatch{Body: []database.BodyMatcher{{Path: "$.order.customer", ValueMatcher: database.ValueMatcher{Contains: "x"}}}}, false},
+		{
+			name: "all conditions",
+			match: database.StubMatch{
+				Method:  "POST",
+				Path:    &database.ValueMatcher{Contains: "/orders/"},
+				Headers: map[string]database.ValueMatcher{"Content-Type": {Contains: "json"}},
+				Query:   map[string]database.ValueMatcher{"mode": {EqualTo: str("test")}},
+				Body:    []database.BodyMatcher{{Path: "order.total", ValueMatcher: database.ValueMatcher{Matches: `^\d+\.\d{2}$`}}},
+			},
+			want: true,
+		},
+		{
+			name: "one failing condition",
+			match: database.StubMatch{
+				Method: "POST",
+				Query:  map[string]database.ValueMatcher{"mode": {EqualTo: str("live")}},
+			},
+			want: false,
+		},
+	}
+	data := request()
+	for _, tt := range tests {
+		s, err := Compile(database.Stub{Enabled: true, Match: tt.match})
+		if err != nil {
+			t.Errorf("%s: Compile: %v", tt.name, err)
+			continue
+		}
+		if got := s.Matches(data); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesDisabled(t *testing.T) {
+	s, err := Compile(database.Stub{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Matches(request()) {
+		t.Error("disabled stub matched")
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		stub database.Stub
+	}{
+		{"bad path regex", database.Stub{Match: database.StubMatch{Path: &database.ValueMatcher{Matches: "("}}}},
+		{"bad header regex", database.Stub{Match: database.StubMatch{Headers: map[string]database.ValueMatcher{"X-A": {Matches: "["}}}}},
+		{"bad body path", database.Stub{Match: database.StubMatch{Body: []database.BodyMatcher{{Path: "a..b"}}}}},
+		{"timeout too long", database.Stub{Fault: &database.StubFault{Type: FaultTimeout, DurationMS: int(MaxFaultTimeout.Milliseconds()) + 1}}},
+		{"burst of zero", database.Stub{Fault: &database.StubFault{Type: FaultErrorBurst}}},
+		{"period below burst", database.Stub{Fault: &database.StubFault{Type: FaultErrorBurst, Burst: 3, Period: 2}}},
+		{"burst status not an error", database.Stub{Fault: &database.StubFault{Type: FaultErrorBurst, Burst: 1, Status: 200}}},
+		{"unknown fault", database.Stub{Fault: &database.StubFault{Type: "explode"}}},
+		{"state without scenario", database.Stub{RequiredState: "Paid"}},
+		{"bad response", database.Stub{Response: database.ResponseConfig{Status: 700}}},
+	}
+	for _, tt := range tests {
+		if _, err := Compile(tt.stub); err == nil {
+			t.Errorf("%s: Compile succeeded, want error", tt.name)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	compile := func(s database.Stub) *Stub {
+		s.Enabled = true
+		c, err := Compile(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	getOnly := compile(database.Stub{ID: 1, Match: database.StubMatch{Method: "GET"}})
+	started := compile(database.Stub{ID: 2, Scenario: "checkout", RequiredState: database.ScenarioStarted})
+	paid := compile(database.Stub{ID: 3, Scenario: "checkout", RequiredState: "Paid"})
+	fallback := compile(database.Stub{ID: 4})
+	list := []*Stub{getOnly, started, paid, fallback}
+
+	tests := []struct {
+		name   string
+		states map[string]string
+		want   *Stub
+	}{
+		{"missing scenario is started", nil, started},
+		{"scenario in required state", map[string]string{"checkout": "Paid"}, paid},
+		{"no scenario stub in state", map[string]string{"checkout": "Shipped"}, fallback},
+	}
+	for _, tt := range tests {
+		if got := Select(list, request(), tt.states); got != tt.want {
+			t.Errorf("%s: Select = %v, want stub %d", tt.name, got, tt.want.ID)
+		}
+	}
+
+	if got := Select([]*Stub{getOnly}, request(), nil); got != nil {
+		t.Errorf("Select = stub %d, want none", got.ID)
+	}
+	if !UsesScenarios(list) || UsesScenarios([]*Stub{getOnly, fallback}) {
+		t.Error("UsesScenarios does not reflect stubs requiring a state")
+	}
+}
+
+func TestInBurst(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault *database.StubFault
+		fails []bool
+	}{
+		{"no fault", nil, []bool{false, false}},
+		{"other fault", &database.StubFault{Type: FaultEmptyResponse}, []bool{false}},
+		{"first two only", &database.StubFault{Type: FaultErrorBurst, Burst: 2}, []bool{true, true, false, false, false}},
+		{"two of every three", &database.StubFault{Type: FaultErrorBurst, Burst: 2, Period: 3}, []bool{true, true, false, true, true, false}},
+	}
+	for _, tt := range tests {
+		s := &Stub{Stub: database.Stub{Fault: tt.fault}}
+		for i, want := range tt.fails {
+			if got := s.InBurst(int64(i + 1)); got != want {
+				t.Errorf("%s: InBurst(%d) = %v, want %v", tt.name, i+1, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"net/http"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseCIDRs("10.0.0.0/8, 192.168.1.1, fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		trusted    []netip.Prefix
+		want       string
+	}{
+		{"no proxies", "203.0.113.7:4321", nil, nil, "203.0.113.7"},
+		{"XFF from untrusted peer ignored", "203.0.113.7:4321", []string{"198.51.100.1"}, trusted, "203.0.113.7"},
+		{"XFF ignored without trusted proxies", "10.0.0.1:4321", []string{"198.51.100.1"}, nil, "10.0.0.1"},
+		{"trusted proxy", "10.0.0.1:4321", []string{"198.51.100.1"}, trusted, "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.1:4321", []string{"198.51.100.1, 192.168.1.1, 10.2.3.4"}, trusted, "198.51.100.1"},
+		{"chain across headers", "10.0.0.1:4321", []string{"198.51.100.1, 192.168.1.1", "10.2.3.4"}, trusted, "198.51.100.1"},
+		{"spoofed leftmost hop", "10.0.0.1:4321", []string{"1.2.3.4, 198.51.100.1"}, trusted, "198.51.100.1"},
+		{"malformed hop", "10.0.0.1:4321", []string{"198.51.100.1, not-an-ip, 10.2.3.4"}, trusted, "10.2.3.4"},
+		{"malformed last hop", "10.0.0.1:4321", []string{"198.51.100.1, garbage"}, trusted, "10.0.0.1"},
+		{"all hops trusted", "10.0.0.1:4321", []string{"10.9.9.9, 192.168.1.1"}, trusted, "10.9.9.9"},
+		{"trusted proxy without XFF", "10.0.0.1:4321", nil, trusted, "10.0.0.1"},
+		{"IPv6 peer", "[2001:db8::1]:4321", nil, trusted, "2001:db8::1"},
+		{"IPv6 trusted proxy", "[fd00::1]:4321", []string{"2001:db8::2"}, trusted, "2001:db8::2"},
+		{"IPv4-mapped peer", "[::ffff:203.0.113.7]:4321", nil, trusted, "203.0.113.7"},
+		{"IPv4-mapped trusted proxy", "[::ffff:10.0.0.1]:4321", []string{"::ffff:198.51.100.1"}, trusted, "198.51.100.1"},
+		{"no port", "203.0.113.7", nil, trusted, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		r := &http.Request{RemoteAddr: tt.remoteAddr, Header: http.Header{}}
+		for _, v := range tt.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := ClientIP(r, tt.trusted); got != tt.want {
+			t.Errorf("%s: ClientIP = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseCIDR(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{"192.0.2.1", "192.0.2.1/32"},
+		{"2001:db8::1/32", "2001:db8::/32"},
+		{"2001:db8::1", "2001:db8::1/128"},
+		{"::ffff:192.0.2.1", "192.0.2.1/32"},
+		{"::ffff:192.0.2.1/120", "192.0.2.0/24"},
+		{"::ffff:192.0.2.1/128", "192.0.2.1/32"},
+		{"10.0.0.0/33", ""},
+		{"10.0.0", ""},
+		{"example.com", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseCIDR(tt.input)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseCIDR(%q) = %s, want error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("ParseCIDR(%q) = %s, %v; want %s", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestContainsIP(t *testing.T) {
+	prefixes, err := ParseCIDRs("192.0.2.0/24, 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"192.0.2.200", true},
+		{"::ffff:192.0.2.200", true},
+		{"192.0.3.1", false},
+		{"2001:db8:1::1", true},
+		{"2001:db9::1", false},
+	}
+	for _, tt := range tests {
+		if got := ContainsIP(prefixes, netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("ContainsIP(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package utils
+
+import "strings"
+
+// StorableText cuts s to at most maxBytes bytes that Postgres can store in a
+// TEXT column. TEXT cannot hold NUL bytes or invalid UTF-8, which includes a
+// rune split by the cut.
+func StorableText(s string, maxBytes int) string {
+	s = strings.ReplaceAll(s, "\x00", "")
+	if len(s) > maxBytes {
+		s = s[:maxBytes]
+	}
+	return strings.ToValidUTF8(s, "")
+}
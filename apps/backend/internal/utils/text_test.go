@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStorableText(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		want string
+	}{
+		{"plain", "invoice.paid", 255, "invoice.paid"},
+		{"NUL bytes", "invoice\x00.paid", 255, "invoice.paid"},
+		{"invalid UTF-8", "invoice\xff.paid", 255, "invoice.paid"},
+		{"too long", strings.Repeat("a", 300), 255, strings.Repeat("a", 255)},
+		{"NUL bytes do not count", strings.Repeat("\x00", 10) + "abc", 3, "abc"},
+		{"cut inside a rune", "abé", 3, "ab"},
+		{"empty", "", 255, ""},
+	}
+	for _, tt := range tests {
+		if got := StorableText(tt.s, tt.max); got != tt.want {
+			t.Errorf("%s: StorableText(%q, %d) = %q, want %q", tt.name, tt.s, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+)
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// errULIDOverflow is returned when 2^80 ULIDs were generated within one
+// millisecond.
+var errULIDOverflow = errors.New("ULID random part overflowed within one millisecond")
+
+// ulidState is the last ULID generated, so ULIDs of the same millisecond
+// increase rather than ordering randomly.
+var ulidState struct {
+	mu   sync.Mutex
+	ms   uint64
+	last [16]byte
+}
+
+// NewULID returns a ULID for t: 26 characters that sort in time order, to
+// the millisecond, followed by 80 random bits. Within one millisecond the
+// random part of the previous ULID is incremented instead, so ULIDs generated
+// in that millisecond sort in the order they were generated.
+func NewULID(t time.Time) (string, error) {
+	ms := uint64(t.UnixMilli())
+
+	ulidState.mu.Lock()
+	defer ulidState.mu.Unlock()
+
+	b := ulidState.last
+	if ms == ulidState.ms {
+		i := 15
+		for ; i >= 6; i-- {
+			b[i]++
+			if b[i] != 0 {
+				break
+			}
+		}
+		if i < 6 {
+			return "", errULIDOverflow
+		}
+	} else {
+		for i := 5; i >= 0; i-- {
+			b[i] = byte(ms >> (8 * (5 - i)))
+		}
+		if _, err := rand.Read(b[6:]); err != nil {
+			return "", err
+		}
+	}
+	ulidState.ms, ulidState.last = ms, b
+	return encodeULID(b), nil
+}
+
+// encodeULID encodes 128 bits as 26 characters of 5 bits, the first holding
+// only the top 3 bits.
+func encodeULID(b [16]byte) string {
+	var out [26]byte
+	var hi, lo uint64
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(b[i])
+		lo = lo<<8 | uint64(b[i+8])
+	}
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// IsULID reports whether s has the form of a ULID.
+func IsULID(s string) bool {
+	if len(s) != 26 || s[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z') || c == 'I' || c == 'L' || c == 'O' || c == 'U' {
+			return false
+		}
+	}
+	return true
+}
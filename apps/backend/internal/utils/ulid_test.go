@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeULID(t *testing.T) {
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xFF
+	}
+	tests := []struct {
+		name string
+		b    [16]byte
+		want string
+	}{
+		{"zero", [16]byte{}, "00000000000000000000000000"},
+		{"max", max, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"},
+		{"lowest bit", [16]byte{15: 1}, "00000000000000000000000001"},
+		{"last character", [16]byte{15: 31}, "0000000000000000000000000Z"},
+		{"carry into next character", [16]byte{15: 32}, "00000000000000000000000010"},
+		{"highest bit", [16]byte{0: 0x80}, "40000000000000000000000000"},
+	}
+	for _, tt := range tests {
+		if got := encodeULID(tt.b); got != tt.want {
+			t.Errorf("%s: encodeULID = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewULIDTimestamp(t *testing.T) {
+	tests := []struct {
+		ms   int64
+		want string
+	}{
+		// The example of the ULID specification.
+		{1469918176385, "01ARYZ6S41"},
+		{0, "0000000000"},
+		{1, "0000000001"},
+		{1<<48 - 1, "7ZZZZZZZZZ"},
+	}
+	for _, tt := range tests {
+		id, err := NewULID(time.UnixMilli(tt.ms))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(id) != 26 || !IsULID(id) {
+			t.Errorf("NewULID(%d) = %q, not a 26-character ULID", tt.ms, id)
+		}
+		if !strings.HasPrefix(id, tt.want) {
+			t.Errorf("NewULID(%d) = %s, want time prefix %s", tt.ms, id, tt.want)
+		}
+	}
+}
+
+func TestNewULIDMonotonic(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	prev, err := NewULID(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		id, err := NewULID(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id <= prev {
+			t.Fatalf("ULID %s generated after %s in the same millisecond does not sort after it", id, prev)
+		}
+		if id[:10] != prev[:10] {
+			t.Fatalf("ULID %s changed the time part of %s", id, prev)
+		}
+		prev = id
+	}
+
+	later, err := NewULID(now.Add(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if later <= prev {
+		t.Errorf("ULID %s of a later millisecond does not sort after %s", later, prev)
+	}
+}
+
+func TestNewULIDOverflow(t *testing.T) {
+	ms := uint64(1700000000001)
+	ulidState.mu.Lock()
+	ulidState.ms = ms
+	for i := 5; i >= 0; i-- {
+		ulidState.last[i] = byte(ms >> (8 * (5 - i)))
+	}
+	for i := 6; i < 16; i++ {
+		ulidState.last[i] = 0xFF
+	}
+	ulidState.mu.Unlock()
+
+	if _, err := NewULID(time.UnixMilli(int64(ms))); err != errULIDOverflow {
+		t.Errorf("NewULID = %v, want %v", err, errULIDOverflow)
+	}
+	if _, err := NewULID(time.UnixMilli(int64(ms) + 1)); err != nil {
+		t.Errorf("NewULID of the next millisecond: %v", err)
+	}
+}
+
+func TestIsULID(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"01ARYZ6S41TSV4RRFFQ69G5FAV", true},
+		{"7ZZZZZZZZZZZZZZZZZZZZZZZZZ", true},
+		{"01ARYZ6S41TSV4RRFFQ69G5FA", false},
+		{"01ARYZ6S41TSV4RRFFQ69G5FAVX", false},
+		{"81ARYZ6S41TSV4RRFFQ69G5FAV", false},
+		{"01ARYZ6S41TSV4RRFFQ69G5FAI", false},
+		{"01ARYZ6S41TSV4RRFFQ69G5FAL", false},
+		{"01ARYZ6S41TSV4RRFFQ69G5FAO", false},
+		{"01ARYZ6S41TSV4RRFFQ69G5FAU", false},
+		{"01aryz6s41tsv4rrffq69g5fav", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsULID(tt.s); got != tt.want {
+			t.Errorf("IsULID(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
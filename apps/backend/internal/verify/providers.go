@@ -0,0 +1,216 @@
+package verify
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+func hmacSHA256(key []byte, parts ...string) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, p := range parts {
+		mac.Write([]byte(p))
+	}
+	return mac.Sum(nil)
+}
+
+// github verifies X-Hub-Signature-256: "sha256=" and the hex HMAC-SHA256 of
+// the body.
+type github struct{}
+
+func (github) Verify(req *Request, secret string, _ time.Duration) error {
+	header := req.Header.Get("X-Hub-Signature-256")
+	if header == "" {
+		return fmt.Errorf("%w: no X-Hub-Signature-256 header", ErrMissingSignature)
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil || !strings.HasPrefix(header, "sha256=") {
+		return fmt.Errorf("%w: malformed X-Hub-Signature-256 header", ErrInvalidSignature)
+	}
+	if !hmac.Equal(hmacSHA256([]byte(secret), string(req.Body)), sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// stripe verifies Stripe-Signature: "t=<unix>,v1=<hex>,..." where each v1 is
+// the HMAC-SHA256 of "<t>.<body>". Stripe sends several v1 values while an
+// endpoint secret is being rolled.
+type stripe struct{}
+
+func (stripe) Verify(req *Request, secret string, tolerance time.Duration) error {
+	header := req.Header.Get("Stripe-Signature")
+	if header == "" {
+		return fmt.Errorf("%w: no Stripe-Signature header", ErrMissingSignature)
+	}
+	var timestamp string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			if sig, err := hex.DecodeString(value); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	if len(sigs) == 0 {
+		return fmt.Errorf("%w: no v1 signature in Stripe-Signature header", ErrMissingSignature)
+	}
+	if err := checkTimestamp(timestamp, req.Received, tolerance); err != nil {
+		return err
+	}
+	if !anyEqual(hmacSHA256([]byte(secret), timestamp, ".", string(req.Body)), sigs) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// slack verifies X-Slack-Signature: "v0=" and the hex HMAC-SHA256 of
+// "v0:<X-Slack-Request-Timestamp>:<body>".
+type slack struct{}
+
+func (slack) Verify(req *Request, secret string, tolerance time.Duration) error {
+	header := req.Header.Get("X-Slack-Signature")
+	if header == "" {
+		return fmt.Errorf("%w: no X-Slack-Signature header", ErrMissingSignature)
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(header, "v0="))
+	if err != nil || !strings.HasPrefix(header, "v0=") {
+		return fmt.Errorf("%w: malformed X-Slack-Signature header", ErrInvalidSignature)
+	}
+	timestamp := req.Header.Get("X-Slack-Request-Timestamp")
+	if err := checkTimestamp(timestamp, req.Received, tolerance); err != nil {
+		return err
+	}
+	if !hmac.Equal(hmacSHA256([]byte(secret), "v0:", timestamp, ":", string(req.Body)), sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// shopify verifies X-Shopify-Hmac-Sha256: the base64 HMAC-SHA256 of the
+// body.
+type shopify struct{}
+
+func (shopify) Verify(req *Request, secret string, _ time.Duration) error {
+	header := req.Header.Get("X-Shopify-Hmac-Sha256")
+	if header == "" {
+		return fmt.Errorf("%w: no X-Shopify-Hmac-Sha256 header", ErrMissingSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return fmt.Errorf("%w: malformed X-Shopify-Hmac-Sha256 header", ErrInvalidSignature)
+	}
+	if !hmac.Equal(hmacSHA256([]byte(secret), string(req.Body)), sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// twilio verifies X-Twilio-Signature: the base64 HMAC-SHA1 of the URL
+// followed, for form posts, by every parameter name and value in sorted
+// order. JSON posts instead carry a bodySHA256 query parameter covering the
+// body.
+type twilio struct{}
+
+func (twilio) Verify(req *Request, secret string, _ time.Duration) error {
+	header := req.Header.Get("X-Twilio-Signature")
+	if header == "" {
+		return fmt.Errorf("%w: no X-Twilio-Signature header", ErrMissingSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return fmt.Errorf("%w: malformed X-Twilio-Signature header", ErrInvalidSignature)
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return fmt.Errorf("%w: cannot parse request URL", ErrInvalidSignature)
+	}
+
+	var data strings.Builder
+	data.WriteString(req.URL)
+	if bodyHash := u.Query().Get("bodySHA256"); bodyHash != "" {
+		sum := sha256.Sum256(req.Body)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), bodyHash) {
+			return fmt.Errorf("%w: body does not match bodySHA256", ErrInvalidSignature)
+		}
+	} else if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(req.Body))
+		if err != nil {
+			return fmt.Errorf("%w: malformed form body", ErrInvalidSignature)
+		}
+		keys := make([]string, 0, len(form))
+		for key := range form {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			values := form[key]
+			sort.Strings(values)
+			for _, value := range values {
+				data.WriteString(key)
+				data.WriteString(value)
+			}
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(data.String()))
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// standardWebhooks verifies the Standard Webhooks scheme: webhook-signature
+// holds space-separated "v1,<base64>" HMAC-SHA256 signatures of
+// "<webhook-id>.<webhook-timestamp>.<body>", keyed with the base64 secret
+// after its "whsec_" prefix.
+type standardWebhooks struct{}
+
+func (standardWebhooks) Verify(req *Request, secret string, tolerance time.Duration) error {
+	header := req.Header.Get("Webhook-Signature")
+	if header == "" {
+		return fmt.Errorf("%w: no webhook-signature header", ErrMissingSignature)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil {
+		return fmt.Errorf("secret is not a base64 Standard Webhooks secret")
+	}
+
+	var sigs [][]byte
+	for _, part := range strings.Fields(header) {
+		version, value, _ := strings.Cut(part, ",")
+		if version != "v1" {
+			continue
+		}
+		if sig, err := base64.StdEncoding.DecodeString(value); err == nil {
+			sigs = append(sigs, sig)
+		}
+	}
+	if len(sigs) == 0 {
+		return fmt.Errorf("%w: no v1 signature in webhook-signature header", ErrMissingSignature)
+	}
+
+	id := req.Header.Get("Webhook-Id")
+	timestamp := req.Header.Get("Webhook-Timestamp")
+	if err := checkTimestamp(timestamp, req.Received, tolerance); err != nil {
+		return err
+	}
+	if !anyEqual(hmacSHA256(key, id, ".", timestamp, ".", string(req.Body)), sigs) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Package verify checks the signatures webhook providers attach to their
+// requests. Each provider scheme is a Verifier, looked up by the webhook's
+// source_type.
+package verify
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"hookinator/internal/sourcetype"
+)
+
+// Results recorded on captured requests.
+const (
+	StatusValid   = "valid"
+	StatusInvalid = "invalid"
+)
+
+// DefaultTolerance is how far a signed timestamp may be from the time of
+// receipt when the webhook does not configure its own tolerance.
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// ErrMissingSignature means the request carries no signature at all.
+	ErrMissingSignature = errors.New("missing signature")
+	// ErrInvalidSignature means no signature matched the secret.
+	ErrInvalidSignature = errors.New("signature mismatch")
+	// ErrTimestamp means the signed timestamp is missing, malformed or
+	// outside the tolerance, which guards against replayed requests.
+	ErrTimestamp = errors.New("timestamp outside tolerance")
+)
+
+// Request is what verifiers see of an incoming request.
+type Request struct {
+	// URL is the full URL the sender called, as it sees it.
+	URL      string
+	Header   http.Header
+	Body     []byte
+	Received time.Time
+}
+
+// Verifier checks a request against a shared secret.
+type Verifier interface {
+	Verify(req *Request, secret string, tolerance time.Duration) error
+}
+
+var verifiers = map[string]Verifier{
+	"github":            github{},
+	"stripe":            stripe{},
+	"slack":             slack{},
+	"shopify":           shopify{},
+	"twilio":            twilio{},
+	"standard-webhooks": standardWebhooks{},
+}
+
+// Lookup returns the verifier for a source type, ignoring case. Source types
+// without a signature scheme, such as "Generic", report false.
+func Lookup(sourceType string) (Verifier, bool) {
+	v, ok := verifiers[sourcetype.Normalize(sourceType)]
+	return v, ok
+}
+
+// SourceTypes lists the source types that support verification.
+func SourceTypes() []string {
+	names := make([]string, 0, len(verifiers))
+	for name := range verifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkTimestamp parses a Unix timestamp in seconds and verifies it is
+// within tolerance of the time the request was received.
+func checkTimestamp(raw string, received time.Time, tolerance time.Duration) error {
+	if raw == "" {
+		return fmt.Errorf("%w: no timestamp", ErrTimestamp)
+	}
+	sec, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed timestamp %q", ErrTimestamp, raw)
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	skew := received.Sub(time.Unix(sec, 0))
+	if skew < -tolerance || skew > tolerance {
+		return fmt.Errorf("%w: signed %s from receipt", ErrTimestamp, skew.Round(time.Second))
+	}
+	return nil
+}
+
+// anyEqual reports whether any candidate equals expected, in constant time
+// per comparison.
+func anyEqual(expected []byte, candidates [][]byte) bool {
+	for _, c := range candidates {
+		if hmac.Equal(expected, c) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,314 @@
+package verify
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// The expected signatures below were computed independently with openssl;
+// the GitHub, Twilio and Standard Webhooks vectors are the examples from the
+// providers' own documentation.
+
+const slackBody = "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebbot&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+
+const twilioForm = "To=%2B18005551212&From=%2B12349013030&Digits=1234&CallSid=CA1234567890ABCDE&Caller=%2B12349013030"
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourceType string
+		secret     string
+		url        string
+		header     http.Header
+		body       string
+		received   time.Time
+		tolerance  time.Duration
+		wantErr    error
+	}{
+		{
+			name:       "github",
+			sourceType: "GitHub",
+			secret:     "It's a Secret to Everybody",
+			header:     http.Header{"X-Hub-Signature-256": {"sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"}},
+			body:       "Hello, World!",
+		},
+		{
+			name:       "github wrong secret",
+			sourceType: "github",
+			secret:     "not the secret",
+			header:     http.Header{"X-Hub-Signature-256": {"sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"}},
+			body:       "Hello, World!",
+			wantErr:    ErrInvalidSignature,
+		},
+		{
+			name:       "github without prefix",
+			sourceType: "github",
+			secret:     "It's a Secret to Everybody",
+			header:     http.Header{"X-Hub-Signature-256": {"757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"}},
+			body:       "Hello, World!",
+			wantErr:    ErrInvalidSignature,
+		},
+		{
+			name:       "github missing header",
+			sourceType: "github",
+			secret:     "It's a Secret to Everybody",
+			body:       "Hello, World!",
+			wantErr:    ErrMissingSignature,
+		},
+		{
+			name:       "stripe",
+			sourceType: "stripe",
+			secret:     "whsec_test_secret",
+			header:     http.Header{"Stripe-Signature": {"t=1492774577,v1=88a022085c6bdb887b02cb26ff76dd681234d9675c0f22844059f55552a8883a"}},
+			body:       `{"id":"evt_test_webhook","object":"event"}`,
+			received:   time.Unix(1492774577, 0).Add(time.Minute),
+		},
+		{
+			name:       "stripe multiple signatures while rolling",
+			sourceType: "stripe",
+			secret:     "whsec_test_secret",
+			header:     http.Header{"Stripe-Signature": {"t=1492774577,v1=aed64a3c4c277a17c2a83b486f08da11442d772bc6e44361371cabce6d56f8af,v1=88a022085c6bdb887b02cb26ff76dd681234d9675c0f22844059f55552a8883a,v0=6ffbb59b2300aae63f272406069a9788598b792a944a07aba816edb039989a39"}},
+			body:       `{"id":"evt_test_webhook","object":"event"}`,
+			received:   time.Unix(1492774577, 0),
+		},
+		{
+			name:       "stripe old secret",
+			sourceType: "stripe",
+			secret:     "whsec_old_secret",
+			header:     http.Header{"Stripe-Signature": {"t=1492774577,v1=aed64a3c4c277a17c2a83b486f08da11442d772bc6e44361371cabce6d56f8af,v1=88a022085c6bdb887b02cb26ff76dd681234d9675c0f22844059f55552a8883a"}},
+			body:       `{"id":"evt_test_webhook","object":"event"}`,
+			received:   time.Unix(1492774577, 0),
+		},
+		{
+			name:       "stripe expired",
+			sourceType: "stripe",
+			secret:     "whsec_test_secret",
+			header:     http.Header{"Stripe-Signature": {"t=1492774577,v1=88a022085c6bdb887b02cb26ff76dd681234d9675c0f22844059f55552a8883a"}},
+			body:       `{"id":"evt_test_webhook","object":"event"}`,
+			received:   time.Unix(1492774577, 0).Add(DefaultTolerance + time.Second),
+			wantErr:    ErrTimestamp,
+		},
+		{
+			name:       "stripe within custom tolerance",
+			sourceType: "stripe",
+			secret:     "whsec_test_secret",
+			header:     http.Header{"Stripe-Signature": {"t=1492774577,v1=88a022085c6bdb887b02cb26ff76dd681234d9675c0f22844059f55552a8883a"}},
+			body:       `{"id":"evt_test_webhook","object":"event"}`,
+			received:   time.Unix(1492774577, 0).Add(time.Hour),
+			tolerance:  2 * time.Hour,
+		},
+		{
+			name:       "stripe tampered body",
+			sourceType: "stripe",
+			secret:     "whsec_test_secret",
+			header:     http.Header{"Stripe-Signature": {"t=1492774577,v1=88a022085c6bdb887b02cb26ff76dd681234d9675c0f22844059f55552a8883a"}},
+			body:       `{"id":"evt_other","object":"event"}`,
+			received:   time.Unix(1492774577, 0),
+			wantErr:    ErrInvalidSignature,
+		},
+		{
+			name:       "stripe only v0",
+			sourceType: "stripe",
+			secret:     "whsec_test_secret",
+			header:     http.Header{"Stripe-Signature": {"t=1492774577,v0=88a022085c6bdb887b02cb26ff76dd681234d9675c0f22844059f55552a8883a"}},
+			body:       `{"id":"evt_test_webhook","object":"event"}`,
+			received:   time.Unix(1492774577, 0),
+			wantErr:    ErrMissingSignature,
+		},
+		{
+			name:       "slack",
+			sourceType: "slack",
+			secret:     "8f742231b10e8888abcd99yyyzzz85a5",
+			header: http.Header{
+				"X-Slack-Signature":         {"v0=bc33a7b8fff46fe303f20f307c19c8f69540cb2fc952bcc56232698f62fd114a"},
+				"X-Slack-Request-Timestamp": {"1531420618"},
+			},
+			body:     slackBody,
+			received: time.Unix(1531420618, 0).Add(-time.Minute),
+		},
+		{
+			name:       "slack expired",
+			sourceType: "slack",
+			secret:     "8f742231b10e8888abcd99yyyzzz85a5",
+			header: http.Header{
+				"X-Slack-Signature":         {"v0=bc33a7b8fff46fe303f20f307c19c8f69540cb2fc952bcc56232698f62fd114a"},
+				"X-Slack-Request-Timestamp": {"1531420618"},
+			},
+			body:     slackBody,
+			received: time.Unix(1531420618, 0).Add(time.Hour),
+			wantErr:  ErrTimestamp,
+		},
+		{
+			name:       "slack missing timestamp",
+			sourceType: "slack",
+			secret:     "8f742231b10e8888abcd99yyyzzz85a5",
+			header:     http.Header{"X-Slack-Signature": {"v0=bc33a7b8fff46fe303f20f307c19c8f69540cb2fc952bcc56232698f62fd114a"}},
+			body:       slackBody,
+			received:   time.Unix(1531420618, 0),
+			wantErr:    ErrTimestamp,
+		},
+		{
+			name:       "shopify",
+			sourceType: "shopify",
+			secret:     "shpss_test_secret",
+			header:     http.Header{"X-Shopify-Hmac-Sha256": {"LnwdZPLBbdjkBwPD/lDL1/rYEGby/GHPtL1aAH6Dxjc="}},
+			body:       `{"id":820982911946154508,"email":"jon@example.com"}`,
+		},
+		{
+			name:       "shopify tampered body",
+			sourceType: "shopify",
+			secret:     "shpss_test_secret",
+			header:     http.Header{"X-Shopify-Hmac-Sha256": {"LnwdZPLBbdjkBwPD/lDL1/rYEGby/GHPtL1aAH6Dxjc="}},
+			body:       `{"id":820982911946154509,"email":"jon@example.com"}`,
+			wantErr:    ErrInvalidSignature,
+		},
+		{
+			name:       "shopify malformed",
+			sourceType: "shopify",
+			secret:     "shpss_test_secret",
+			header:     http.Header{"X-Shopify-Hmac-Sha256": {"not base64!"}},
+			wantErr:    ErrInvalidSignature,
+		},
+		{
+			name:       "twilio form",
+			sourceType: "twilio",
+			secret:     "12345",
+			url:        "https://mycompany.com/myapp.php?foo=1&bar=2",
+			header: http.Header{
+				"X-Twilio-Signature": {"0/KCTR6DLpKmkAf8muzZqo1nDgQ="},
+				"Content-Type":       {"application/x-www-form-urlencoded; charset=utf-8"},
+			},
+			body: twilioForm,
+		},
+		{
+			name:       "twilio different URL",
+			sourceType: "twilio",
+			secret:     "12345",
+			url:        "https://mycompany.com/myapp.php?foo=1&bar=3",
+			header: http.Header{
+				"X-Twilio-Signature": {"0/KCTR6DLpKmkAf8muzZqo1nDgQ="},
+				"Content-Type":       {"application/x-www-form-urlencoded"},
+			},
+			body:    twilioForm,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:       "twilio JSON body",
+			sourceType: "twilio",
+			secret:     "12345",
+			url:        "https://example.com/hooks/abc?bodySHA256=484a2cc5aefecd5dd80287edd227e8e4667f74168eaa0907992ec30c14fe5eec",
+			header: http.Header{
+				"X-Twilio-Signature": {"aTaaxxNjbnQ2kO78roqy/OuswHU="},
+				"Content-Type":       {"application/json"},
+			},
+			body: `{"CallSid":"CA123"}`,
+		},
+		{
+			name:       "twilio JSON body tampered",
+			sourceType: "twilio",
+			secret:     "12345",
+			url:        "https://example.com/hooks/abc?bodySHA256=484a2cc5aefecd5dd80287edd227e8e4667f74168eaa0907992ec30c14fe5eec",
+			header: http.Header{
+				"X-Twilio-Signature": {"aTaaxxNjbnQ2kO78roqy/OuswHU="},
+				"Content-Type":       {"application/json"},
+			},
+			body:    `{"CallSid":"CA124"}`,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:       "standard webhooks",
+			sourceType: "standard-webhooks",
+			secret:     "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw",
+			header: http.Header{
+				"Webhook-Id":        {"msg_p5jXN8AQM9LWM0D4loKWxJek"},
+				"Webhook-Timestamp": {"1614265330"},
+				"Webhook-Signature": {"v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="},
+			},
+			body:     `{"test": 2432232314}`,
+			received: time.Unix(1614265330, 0),
+		},
+		{
+			name:       "standard webhooks multiple signatures",
+			sourceType: "svix",
+			secret:     "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw",
+			header: http.Header{
+				"Webhook-Id":        {"msg_p5jXN8AQM9LWM0D4loKWxJek"},
+				"Webhook-Timestamp": {"1614265330"},
+				"Webhook-Signature": {"v1,Ceo5qEr07ixe2NLpvHk3FH9bwy/WavXrAFQ/9tdO6mc= v1a,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE= v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="},
+			},
+			body:     `{"test": 2432232314}`,
+			received: time.Unix(1614265330, 0),
+		},
+		{
+			name:       "standard webhooks signature of another version only",
+			sourceType: "standard-webhooks",
+			secret:     "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw",
+			header: http.Header{
+				"Webhook-Id":        {"msg_p5jXN8AQM9LWM0D4loKWxJek"},
+				"Webhook-Timestamp": {"1614265330"},
+				"Webhook-Signature": {"v1a,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="},
+			},
+			body:     `{"test": 2432232314}`,
+			received: time.Unix(1614265330, 0),
+			wantErr:  ErrMissingSignature,
+		},
+		{
+			name:       "standard webhooks expired",
+			sourceType: "standard-webhooks",
+			secret:     "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw",
+			header: http.Header{
+				"Webhook-Id":        {"msg_p5jXN8AQM9LWM0D4loKWxJek"},
+				"Webhook-Timestamp": {"1614265330"},
+				"Webhook-Signature": {"v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="},
+			},
+			body:     `{"test": 2432232314}`,
+			received: time.Unix(1614265330, 0).Add(-10 * time.Minute),
+			wantErr:  ErrTimestamp,
+		},
+		{
+			name:       "standard webhooks different ID",
+			sourceType: "standard-webhooks",
+			secret:     "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw",
+			header: http.Header{
+				"Webhook-Id":        {"msg_other"},
+				"Webhook-Timestamp": {"1614265330"},
+				"Webhook-Signature": {"v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="},
+			},
+			body:     `{"test": 2432232314}`,
+			received: time.Unix(1614265330, 0),
+			wantErr:  ErrInvalidSignature,
+		},
+	}
+	for _, tt := range tests {
+		v, ok := Lookup(tt.sourceType)
+		if !ok {
+			t.Errorf("%s: no verifier for source type %q", tt.name, tt.sourceType)
+			continue
+		}
+		header := tt.header
+		if header == nil {
+			header = http.Header{}
+		}
+		received := tt.received
+		if received.IsZero() {
+			received = time.Now()
+		}
+		err := v.Verify(&Request{URL: tt.url, Header: header, Body: []byte(tt.body), Received: received}, tt.secret, tt.tolerance)
+		switch {
+		case tt.wantErr == nil && err != nil:
+			t.Errorf("%s: Verify: %v", tt.name, err)
+		case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLookupUnknown(t *testing.T) {
+	for _, sourceType := range []string{"", "Generic", "gitlab"} {
+		if _, ok := Lookup(sourceType); ok {
+			t.Errorf("Lookup(%q) found a verifier", sourceType)
+		}
+	}
+}
@@ -3,6 +3,8 @@ import type {
   WebhookResponse,
   ApiWebhook,
   WebhookRequest,
+  WebhookRequestFilters,
+  WebhookRequestPage,
 } from "./types";
 
 // Use an environment variable for the API URL
@@ -88,11 +90,103 @@ export async function deleteWebhook(
   }
 }
 
+export async function getWebhookRequestPage(
+  webhookId: string,
+  authToken: string,
+  filters: WebhookRequestFilters = {}
+): Promise<WebhookRequestPage> {
+  const params = new URLSearchParams();
+  for (const [key, value] of Object.entries(filters)) {
+    if (value !== undefined && value !== "") {
+      params.set(key, String(value));
+    }
+  }
+  const query = params.toString();
+  const response = await fetch(
+    `${API_BASE_URL}/inspect/${webhookId}${query ? `?${query}` : ""}`,
+    {
+      method: "GET",
+      headers: {
+        Authorization: `Bearer ${authToken}`,
+      },
+    }
+  );
+
+  if (!response.ok) {
+    const errorData = await response.json();
+    throw new Error(errorData.error || "Failed to fetch webhook requests");
+  }
+
+  const data = await response.json();
+  return {
+    requests: data?.requests || [],
+    next_cursor: data?.next_cursor ?? null,
+  };
+}
+
 export async function getWebhookRequests(
   webhookId: string,
   authToken: string
 ): Promise<WebhookRequest[]> {
-  const response = await fetch(`${API_BASE_URL}/inspect/${webhookId}`, {
+  const page = await getWebhookRequestPage(webhookId, authToken);
+  return page.requests;
+}
+
+export async function getWebhookRequest(
+  webhookId: string,
+  requestId: string,
+  authToken: string
+): Promise<WebhookRequest> {
+  const response = await fetch(
+    `${API_BASE_URL}/inspect/${webhookId}/requests/${requestId}`,
+    {
+      method: "GET",
+      headers: {
+        Authorization: `Bearer ${authToken}`,
+      },
+    }
+  );
+
+  if (!response.ok) {
+    const errorData = await response.json();
+    throw new Error(errorData.error || "Failed to fetch webhook request");
+  }
+
+  return response.json();
+}
+
+export async function deleteWebhookRequest(
+  webhookId: string,
+  requestId: string,
+  authToken: string
+): Promise<void> {
+  const response = await fetch(
+    `${API_BASE_URL}/inspect/${webhookId}/requests/${requestId}`,
+    {
+      method: "DELETE",
+      headers: {
+        Authorization: `Bearer ${authToken}`,
+      },
+    }
+  );
+
+  if (!response.ok) {
+    const errorData = await response.json();
+    throw new Error(errorData.error || "Failed to delete webhook request");
+  }
+}
+
+export async function searchWebhookRequests(
+  authToken: string,
+  filters: WebhookRequestFilters & { webhook_id?: string } = {}
+): Promise<WebhookRequestPage> {
+  const params = new URLSearchParams();
+  for (const [key, value] of Object.entries(filters)) {
+    if (value !== undefined && value !== "") {
+      params.set(key, String(value));
+    }
+  }
+  const response = await fetch(`${API_BASE_URL}/search?${params.toString()}`, {
     method: "GET",
     headers: {
       Authorization: `Bearer ${authToken}`,
@@ -101,11 +195,109 @@ export async function getWebhookRequests(
 
   if (!response.ok) {
     const errorData = await response.json();
-    throw new Error(errorData.error || "Failed to fetch webhook requests");
+    throw new Error(errorData.error || "Failed to search webhook requests");
   }
 
   const data = await response.json();
-  return data || [];
+  return {
+    requests: data?.requests || [],
+    next_cursor: data?.next_cursor ?? null,
+  };
+}
+
+async function createStreamTicket(
+  webhookId: string,
+  authToken: string
+): Promise<string> {
+  const response = await fetch(
+    `${API_BASE_URL}/inspect/${webhookId}/stream/ticket`,
+    {
+      method: "POST",
+      headers: {
+        Authorization: `Bearer ${authToken}`,
+      },
+    }
+  );
+
+  if (!response.ok) {
+    const errorData = await response.json();
+    throw new Error(errorData.error || "Failed to open request stream");
+  }
+
+  const data = await response.json();
+  return data.ticket;
+}
+
+// How many recent event IDs are remembered to skip the requests a resumed
+// stream sends again.
+const STREAM_SEEN_IDS = 1000;
+
+export function streamWebhookRequests(
+  webhookId: string,
+  authToken: string,
+  onRequest: (request: WebhookRequest) => void,
+  onReset?: () => void
+): { close: () => void } {
+  // EventSource cannot send headers, so each connection uses a single-use
+  // ticket. Its own reconnects would reuse a spent ticket, so the stream is
+  // reopened here with a new one, resuming after the last request received.
+  // A resumed stream starts a little early, so requests already received
+  // are skipped; if it cannot resume at all it sends a reset event, after
+  // which the requests should be reloaded.
+  let source: EventSource | null = null;
+  let lastEventId = "";
+  let closed = false;
+  const seen = new Set<string>();
+
+  const connect = async () => {
+    try {
+      const params = new URLSearchParams({
+        ticket: await createStreamTicket(webhookId, authToken),
+      });
+      if (lastEventId) {
+        params.set("last_event_id", lastEventId);
+      }
+      if (closed) {
+        return;
+      }
+      source = new EventSource(
+        `${API_BASE_URL}/inspect/${webhookId}/stream?${params.toString()}`
+      );
+      source.addEventListener("request", (event) => {
+        const message = event as MessageEvent;
+        if (seen.has(message.lastEventId)) {
+          return;
+        }
+        seen.add(message.lastEventId);
+        if (seen.size > STREAM_SEEN_IDS) {
+          seen.delete(seen.values().next().value as string);
+        }
+        lastEventId = message.lastEventId;
+        onRequest(JSON.parse(message.data));
+      });
+      source.addEventListener("reset", () => {
+        onReset?.();
+      });
+      source.onerror = () => {
+        source?.close();
+        if (!closed) {
+          setTimeout(connect, 1000);
+        }
+      };
+    } catch {
+      if (!closed) {
+        setTimeout(connect, 5000);
+      }
+    }
+  };
+  connect();
+
+  return {
+    close: () => {
+      closed = true;
+      source?.close();
+    },
+  };
 }
 
 export async function updateWebhook(
@@ -18,8 +18,30 @@ export interface ApiWebhook {
 }
 
 export interface WebhookRequest {
+  public_id?: string;
+  webhook_id?: string;
   timestamp: string;
   method: string;
   headers: Record<string, string>;
   body: string;
+  pinned?: boolean;
+}
+
+export interface WebhookRequestFilters {
+  method?: string;
+  event_type?: string;
+  header?: string;
+  header_value?: string;
+  since?: string;
+  until?: string;
+  body_contains?: string;
+  q?: string;
+  jsonpath?: string;
+  cursor?: string;
+  limit?: number;
+}
+
+export interface WebhookRequestPage {
+  requests: WebhookRequest[];
+  next_cursor: string | null;
 }
@@ -24,5 +24,5 @@ export function generateWebhookUrl(webhookId: string): string {
   if (!baseUrl) {
     throw new Error("NEXT_PUBLIC_API_URL environment variable is not set");
   }
-  return `${baseUrl}/webhook/${webhookId}`;
+  return `${baseUrl}/hooks/${webhookId}`;
 }